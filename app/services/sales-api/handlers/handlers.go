@@ -1,43 +1,121 @@
 package handlers
 
 import (
+	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/app/services/sales-api/handlers/v1/testgrp"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency/stores/idempotencydb"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user/stores/userdb"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/paging"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 	"net/http"
 	"os"
+	"time"
 )
 
 // APIMuxConfig contains all the mandatory systems required by handlers
 type APIMuxConfig struct {
 	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
+	Log      *logger.Logger
 	Auth     *auth.Auth
 	DB       *sqlx.DB
+
+	// Cursors signs the cursors handed out for keyset paging.
+	Cursors *paging.Cursors
+
+	// WriteTimeout is the write timeout of the server, streaming endpoints
+	// extend it as they make progress.
+	WriteTimeout time.Duration
+
+	// RequestTimeout bounds how long a route may run before its work is
+	// canceled. The import runs up to WriteTimeout and the export streams
+	// without a bound.
+	RequestTimeout time.Duration
+
+	// IdempotencyTTL is how long the response of a request made with an
+	// idempotency key is kept for replay.
+	IdempotencyTTL time.Duration
+
+	// RateLimits holds the token buckets of the rate limited routes. The
+	// limits are applied to the user routes and to the token route.
+	RateLimits ratelimit.Store
+	UsersLimit ratelimit.Limit
+	TokenLimit ratelimit.Limit
+
+	// MaxBodyBytes is the largest request body accepted, zero keeps the
+	// default of the web package.
+	MaxBodyBytes int64
+
+	// CORSAllowedOrigins lists the origins browsers may call the API from,
+	// empty turns CORS off.
+	CORSAllowedOrigins []string
+
+	// Tracer records the spans of the requests, nil only propagates the
+	// trace context.
+	Tracer *trace.Tracer
 }
 
 // APIMux constructs a http.Handler with all application routes defined
-func APIMux(cfg APIMuxConfig) *web.App {
+func APIMux(cfg APIMuxConfig) (*web.App, error) {
 	// Panics() should always be the last middleware, so it's as close to the handler as possible
-	app := web.NewApp(cfg.Shutdown, mid.Logger(cfg.Log), mid.Errors(cfg.Log), mid.Metrics(), mid.Panics())
+	// Compress wraps Errors so error responses are compressed as well.
+	// Metrics wraps both so it sees the final status and the bytes sent.
+	app := web.NewApp(cfg.Shutdown, mid.Logger(cfg.Log), mid.Metrics(), mid.Compress(cfg.Log, mid.CompressConfig{Brotli: true}), mid.Errors(cfg.Log), mid.Panics())
+	if cfg.MaxBodyBytes > 0 {
+		app.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	}
+	app.SetTracer(cfg.Tracer)
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		err := app.EnableCORS(web.CORSConfig{
+			AllowedOrigins: cfg.CORSAllowedOrigins,
+			AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "Content-Encoding", "Idempotency-Key", "X-Debug-Log", "traceparent", "tracestate"},
+			ExposedHeaders: []string{
+				"ETag", "Retry-After", "Idempotent-Replayed",
+				"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+				"X-Total-Count", "X-Page", "X-Rows-Per-Page", "X-Next-Cursor", "X-Prev-Cursor",
+			},
+			MaxAge: time.Hour,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("enabling cors: %w", err)
+		}
+	}
+
+	timeout := mid.Timeout(cfg.RequestTimeout)
 
 	// bind a route to the mux(app variable). If a req with GET method comes in, execute this handler
-	app.Handle(http.MethodGet, "/test", testgrp.Test)
-	app.Handle(http.MethodGet, "/test/auth", testgrp.Test, mid.Authenticate(cfg.Auth), mid.Authorize(cfg.Auth, auth.RuleAdminOnly))
+	app.Handle(http.MethodGet, "/test", testgrp.Test, timeout)
+	app.Handle(http.MethodGet, "/test/auth", testgrp.Test, timeout, mid.Authenticate(cfg.Auth), mid.Authorize(cfg.Auth, auth.RuleAdminOnly))
 
 	// =============================================================================
 
 	usrCore := user.NewCore(userdb.NewStore(cfg.Log, cfg.DB))
+	idemCore := idempotency.NewCore(idempotencydb.NewStore(cfg.Log, cfg.DB))
+
+	// A request can't run past the write timeout, so a key still pending after
+	// twice that was claimed by a request that will never complete.
+	idem := mid.Idempotency(cfg.Log, idemCore, cfg.IdempotencyTTL, 2*cfg.WriteTimeout)
+	usersLimit := mid.RateLimit(cfg.Log, cfg.RateLimits, mid.RateLimitConfig{Name: "users", Limit: cfg.UsersLimit})
+	tokenLimit := mid.RateLimit(cfg.Log, cfg.RateLimits, mid.RateLimitConfig{Name: "token", Limit: cfg.TokenLimit, Key: mid.KeyByIP})
 
-	ugh := usergrp.New(usrCore, cfg.Auth)
+	ugh := usergrp.New(usrCore, cfg.Auth, cfg.Cursors, cfg.WriteTimeout)
 
-	app.Handle(http.MethodGet, "/users", ugh.Query)
+	app.Handle(http.MethodGet, "/users/token/:kid", ugh.Token, timeout, tokenLimit)
+	app.Handle(http.MethodGet, "/users", ugh.Query, timeout, usersLimit)
+	app.Handle(http.MethodPost, "/users/import", ugh.Import, mid.Timeout(cfg.WriteTimeout), mid.Authenticate(cfg.Auth), usersLimit, mid.Authorize(cfg.Auth, auth.RuleAdminOnly), idem)
+	app.Handle(http.MethodGet, "/users/export", ugh.Export, mid.Authenticate(cfg.Auth), usersLimit, mid.Authorize(cfg.Auth, auth.RuleAdminOnly))
+	app.Handle(http.MethodGet, "/users/:id", ugh.QueryByID, timeout, mid.Authenticate(cfg.Auth), usersLimit)
+	app.Handle(http.MethodPut, "/users/:id", ugh.Update, timeout, mid.Authenticate(cfg.Auth), usersLimit)
+	app.Handle(http.MethodDelete, "/users/:id", ugh.Delete, timeout, mid.Authenticate(cfg.Auth), usersLimit)
+	app.Handle(http.MethodPost, "/users/:id/restore", ugh.Restore, timeout, mid.Authenticate(cfg.Auth), usersLimit, mid.Authorize(cfg.Auth, auth.RuleAdminOnly), idem)
 
-	return app
+	return app, nil
 }
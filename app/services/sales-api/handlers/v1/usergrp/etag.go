@@ -0,0 +1,60 @@
+package usergrp
+
+import (
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	v1Web "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingIfMatch is returned when a request that changes a user doesn't
+// tell us which version of the user it is based on.
+var ErrMissingIfMatch = errors.New("If-Match header is required")
+
+// setETag returns the version of the user to the client as an ETag. The client
+// sends it back in the If-Match header to update or delete the user.
+func setETag(w http.ResponseWriter, usr user.User) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(usr.Version)))
+}
+
+// versionConflict returns the 412 for a user that changed in between. The
+// current version is sent as the ETag when it's known, but the client still
+// has to fetch the user again to see what changed before retrying.
+func versionConflict(w http.ResponseWriter, err error) error {
+	var vc *user.VersionConflictError
+	if errors.As(err, &vc) {
+		setETag(w, user.User{Version: vc.Current})
+	}
+
+	return v1Web.NewRequestError(user.ErrVersionConflict, http.StatusPreconditionFailed)
+}
+
+// checkIfMatch validates the If-Match header against the current version of
+// the user. A "*" matches any version.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, usr user.User) error {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return v1Web.NewRequestError(ErrMissingIfMatch, http.StatusPreconditionRequired)
+	}
+
+	if ifMatch == "*" {
+		return nil
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+		version, err := strconv.Unquote(tag)
+		if err != nil {
+			version = tag
+		}
+
+		if version == strconv.Itoa(usr.Version) {
+			return nil
+		}
+	}
+
+	return versionConflict(w, &user.VersionConflictError{Current: usr.Version})
+}
@@ -0,0 +1,4 @@
+package usergrp
+
+// ParseConditions exposes parseConditions to the tests.
+var ParseConditions = parseConditions
@@ -1,11 +1,14 @@
 package usergrp
 
 import (
+	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/cview/user/summary"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 	"net/http"
 	"net/mail"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,34 +16,39 @@ import (
 
 // we have different filter functions depending on the route.
 
+// filterField describes a field clients can filter users on.
+type filterField struct {
+	field     string
+	defaultOp string
+	parse     func(value string) (any, error)
+}
+
+var filterFields = map[string]filterField{
+	"user_id":      {user.FilterByID, filter.EQ, parseUUID},
+	"name":         {user.FilterByName, filter.LIKE, parseString},
+	"email":        {user.FilterByEmail, filter.EQ, parseEmail},
+	"roles":        {user.FilterByRoles, filter.EQ, parseRole},
+	"department":   {user.FilterByDepartment, filter.EQ, parseString},
+	"enabled":      {user.FilterByEnabled, filter.EQ, parseBool},
+	"date_created": {user.FilterByDateCreated, filter.EQ, parseTime},
+}
+
 func parseFilter(r *http.Request) (user.QueryFilter, error) {
 	const (
-		filterByUserID           = "user_id"
-		filterByEmail            = "email"
 		filterByStartCreatedDate = "start_created_date"
 		filterByEndCreatedDate   = "end_created_date"
-		filterByName             = "name"
+		filterByIncludeDeleted   = "include_deleted"
 	)
 
 	values := r.URL.Query()
 
 	var filter user.QueryFilter
 
-	if userID := values.Get(filterByUserID); userID != "" {
-		id, err := uuid.Parse(userID)
-		if err != nil {
-			return user.QueryFilter{}, validate.NewFieldsError(filterByUserID, err)
-		}
-		filter.WithUserID(id)
-	}
-
-	if email := values.Get(filterByEmail); email != "" {
-		addr, err := mail.ParseAddress(email)
-		if err != nil {
-			return user.QueryFilter{}, validate.NewFieldsError(filterByEmail, err)
-		}
-		filter.WithEmail(*addr)
+	conditions, err := parseConditions(r)
+	if err != nil {
+		return user.QueryFilter{}, err
 	}
+	filter.Conditions = conditions
 
 	if createdDate := values.Get(filterByStartCreatedDate); createdDate != "" {
 		t, err := time.Parse(time.RFC3339, createdDate)
@@ -58,8 +66,12 @@ func parseFilter(r *http.Request) (user.QueryFilter, error) {
 		filter.WithEndCreatedDate(t)
 	}
 
-	if name := values.Get(filterByName); name != "" {
-		filter.WithName(name)
+	if includeDeleted := values.Get(filterByIncludeDeleted); includeDeleted != "" {
+		b, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return user.QueryFilter{}, validate.NewFieldsError(filterByIncludeDeleted, err)
+		}
+		filter.WithIncludeDeleted(b)
 	}
 
 	/* there are some validation tags in the QueryFilter model in business layer! So we call the validate() method here,
@@ -71,6 +83,87 @@ func parseFilter(r *http.Request) (user.QueryFilter, error) {
 	return filter, nil
 }
 
+// parseConditions parses the filter params of the query string, like
+// "roles[in]=ADMIN,USER" or "enabled=false", into conditions.
+func parseConditions(r *http.Request) ([]filter.Condition, error) {
+	defaults := make(map[string]string, len(filterFields))
+	for name, ff := range filterFields {
+		defaults[name] = ff.defaultOp
+	}
+
+	params, err := filter.Parse(r, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := make([]filter.Condition, len(params))
+	for i, param := range params {
+		ff := filterFields[param.Field]
+		key := fmt.Sprintf("%s[%s]", param.Field, param.Operator)
+
+		// A LIKE matches part of a value, so it can't be parsed as a whole one.
+		if param.Operator == filter.LIKE {
+			conditions[i] = filter.NewCondition(ff.field, param.Operator, param.Value)
+			continue
+		}
+
+		if param.Operator == filter.IN {
+			values := param.Values()
+			for _, v := range values {
+				if _, err := ff.parse(v); err != nil {
+					return nil, validate.NewFieldsError(key, err)
+				}
+			}
+			conditions[i] = filter.NewCondition(ff.field, param.Operator, values)
+			continue
+		}
+
+		value, err := ff.parse(param.Value)
+		if err != nil {
+			return nil, validate.NewFieldsError(key, err)
+		}
+		conditions[i] = filter.NewCondition(ff.field, param.Operator, value)
+	}
+
+	return conditions, nil
+}
+
+func parseString(value string) (any, error) {
+	return value, nil
+}
+
+func parseUUID(value string) (any, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return id.String(), nil
+}
+
+func parseEmail(value string) (any, error) {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return nil, err
+	}
+	return addr.Address, nil
+}
+
+func parseRole(value string) (any, error) {
+	role, err := user.ParseRole(value)
+	if err != nil {
+		return nil, err
+	}
+	return role.Name(), nil
+}
+
+func parseBool(value string) (any, error) {
+	return strconv.ParseBool(value)
+}
+
+func parseTime(value string) (any, error) {
+	return time.Parse(time.RFC3339, value)
+}
+
 // ==============================================================================
 
 func parseSummaryFilter(r *http.Request) (summary.QueryFilter, error) {
@@ -0,0 +1,78 @@
+package usergrp_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseConditions(t *testing.T) {
+	created := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query string
+		exp   filter.Condition
+	}{
+		{"uuid", "user_id=45B5FBD3-755F-4379-8F07-A58D4A30FA2F", filter.NewCondition(user.FilterByID, filter.EQ, "45b5fbd3-755f-4379-8f07-a58d4a30fa2f")},
+		{"email", "email=Bill <bill@ardanlabs.com>", filter.NewCondition(user.FilterByEmail, filter.EQ, "bill@ardanlabs.com")},
+		{"role", "roles=ADMIN", filter.NewCondition(user.FilterByRoles, filter.EQ, "ADMIN")},
+		{"roles in", "roles[in]=ADMIN, USER", filter.NewCondition(user.FilterByRoles, filter.IN, []string{"ADMIN", "USER"})},
+		{"bool", "enabled[ne]=false", filter.NewCondition(user.FilterByEnabled, filter.NE, false)},
+		{"time", "date_created[gte]=2023-03-01T12:00:00Z", filter.NewCondition(user.FilterByDateCreated, filter.GTE, created)},
+		{"like is kept whole", "email[like]=ardanlabs", filter.NewCondition(user.FilterByEmail, filter.LIKE, "ardanlabs")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, err := usergrp.ParseConditions(newRequest(tt.query))
+			if err != nil {
+				t.Fatalf("Should be able to parse the conditions : %s", err)
+			}
+			if len(conditions) != 1 || !reflect.DeepEqual(conditions[0], tt.exp) {
+				t.Fatalf("Should get back the condition : exp %+v got %+v", tt.exp, conditions)
+			}
+		})
+	}
+}
+
+func Test_ParseConditionsRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"uuid", "user_id=1", "user_id[eq]"},
+		{"email", "email=bill", "email[eq]"},
+		{"role", "roles=ROOT", "roles[eq]"},
+		{"roles in", "roles[in]=ADMIN,ROOT", "roles[in]"},
+		{"bool", "enabled=maybe", "enabled[eq]"},
+		{"time", "date_created[gt]=yesterday", "date_created[gt]"},
+		{"malformed operator", "roles[IN]=ADMIN", "roles[IN]"},
+		{"unknown operator", "name[foo]=bill", "name[foo]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := usergrp.ParseConditions(newRequest(tt.query))
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 || fe[0].Field != tt.field {
+				t.Fatalf("Should reject the filter with a field error on %s : %v", tt.field, err)
+			}
+		})
+	}
+}
+
+// newRequest constructs a request with the query string escaped.
+func newRequest(query string) *http.Request {
+	values, _ := url.ParseQuery(query)
+	return httptest.NewRequest(http.MethodGet, "/users?"+values.Encode(), nil)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/fields"
+	"net/http"
 	"net/mail"
 	"time"
 )
@@ -21,6 +23,39 @@ type AppUser struct {
 	Enabled      bool     `json:"enabled"`
 	DateCreated  string   `json:"dateCreated"`
 	DateUpdated  string   `json:"dateUpdated"`
+	DateDeleted  string   `json:"dateDeleted,omitempty"`
+}
+
+// appUserFields maps the JSON fields of AppUser to the user fields a query
+// can be limited to.
+var appUserFields = map[string]string{
+	"id":          user.FieldID,
+	"name":        user.FieldName,
+	"email":       user.FieldEmail,
+	"roles":       user.FieldRoles,
+	"department":  user.FieldDepartment,
+	"enabled":     user.FieldEnabled,
+	"dateCreated": user.FieldDateCreated,
+	"dateUpdated": user.FieldDateUpdated,
+	"dateDeleted": user.FieldDateDeleted,
+}
+
+// parseFields parses the sparse fieldset of the request and returns it with
+// the matching user fields for the store.
+func parseFields(r *http.Request) (fields.Set, []string, error) {
+	set, err := fields.Parse[AppUser](r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coreFields := make([]string, 0, len(set))
+	for _, field := range set {
+		if f, exists := appUserFields[field]; exists {
+			coreFields = append(coreFields, f)
+		}
+	}
+
+	return set, coreFields, nil
 }
 
 func toAppUser(usr user.User) AppUser {
@@ -29,6 +64,11 @@ func toAppUser(usr user.User) AppUser {
 		roles[i] = role.Name()
 	}
 
+	var dateDeleted string
+	if usr.IsDeleted() {
+		dateDeleted = usr.DateDeleted.Format(time.RFC3339)
+	}
+
 	return AppUser{
 		ID:           usr.ID.String(),
 		Name:         usr.Name,
@@ -39,6 +79,7 @@ func toAppUser(usr user.User) AppUser {
 		Enabled:      usr.Enabled,
 		DateCreated:  usr.DateCreated.Format(time.RFC3339),
 		DateUpdated:  usr.DateUpdated.Format(time.RFC3339),
+		DateDeleted:  dateDeleted,
 	}
 }
 
@@ -8,34 +8,39 @@ import (
 	"net/http"
 )
 
-func parseOrder(r *http.Request) (order.By, error) {
+func parseOrder(r *http.Request) ([]order.By, error) {
 	const (
-		orderByID      = "user_id"
-		orderByName    = "name"
-		orderByEmail   = "email"
-		orderByRoles   = "roles"
-		orderByEnabled = "enabled"
+		orderByID         = "user_id"
+		orderByName       = "name"
+		orderByEmail      = "email"
+		orderByRoles      = "roles"
+		orderByEnabled    = "enabled"
+		orderByDepartment = "department"
 	)
 
 	// it was better to define the values that are imported from business layer, in this layer instead
 	var orderByFields = map[string]string{
-		orderByID:      user.OrderByID,
-		orderByName:    user.OrderByName,
-		orderByEmail:   user.OrderByEmail,
-		orderByRoles:   user.OrderByRoles,
-		orderByEnabled: user.OrderByEnabled,
+		orderByID:         user.OrderByID,
+		orderByName:       user.OrderByName,
+		orderByEmail:      user.OrderByEmail,
+		orderByRoles:      user.OrderByRoles,
+		orderByEnabled:    user.OrderByEnabled,
+		orderByDepartment: user.OrderByDepartment,
 	}
 
 	orderBy, err := order.Parse(r, order.NewBy(orderByID, order.ASC))
 	if err != nil {
-		return order.By{}, err
+		return nil, err
 	}
 
-	if _, exists := orderByFields[orderBy.Field]; !exists {
-		return order.By{}, validate.NewFieldsError(orderBy.Field, errors.New("order field does not exist"))
-	}
+	for i, by := range orderBy {
+		field, exists := orderByFields[by.Field]
+		if !exists {
+			return nil, validate.NewFieldsError(by.Field, errors.New("order field does not exist"))
+		}
 
-	orderBy.Field = orderByFields[orderBy.Field]
+		orderBy[i].Field = field
+	}
 
 	return orderBy, nil
 }
@@ -6,12 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
 	v1Web "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/fields"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/paging"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
 	"net/http"
 	"net/mail"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -22,14 +27,21 @@ var ErrInvalidID = errors.New("ID is not in its proper form")
 
 // Handlers manages the set of user endpoints. Handlers take whatever business core packages we need.
 type Handlers struct {
-	User *user.Core
-	Auth *auth.Auth
+	User    *user.Core
+	Auth    *auth.Auth
+	Cursors *paging.Cursors
+
+	// WriteTimeout is how long an export may wait to write each chunk of
+	// the stream to the client.
+	WriteTimeout time.Duration
 }
 
-func New(user *user.Core, auth *auth.Auth) *Handlers {
+func New(user *user.Core, auth *auth.Auth, cursors *paging.Cursors, writeTimeout time.Duration) *Handlers {
 	return &Handlers{
-		User: user,
-		Auth: auth,
+		User:         user,
+		Auth:         auth,
+		Cursors:      cursors,
+		WriteTimeout: writeTimeout,
 	}
 }
 
@@ -51,10 +63,64 @@ func (h *Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Re
 	return web.Respond(ctx, w, usr, http.StatusCreated)
 }
 
-// Update updates a user in the system.
+// Import adds the users of a CSV document or a JSON array to the system. Every
+// row is validated like a single new user and the report lists the errors of
+// each invalid row. The dryRun query parameter only validates the rows, mode
+// selects between one transaction for all rows (batch) or one per row (row).
+func (h *Handlers) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	values := r.URL.Query()
+
+	cfg := user.ImportConfig{
+		Mode: values.Get("mode"),
+	}
+
+	if v := values.Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return validate.NewFieldsError("dryRun", err)
+		}
+		cfg.DryRun = dryRun
+	}
+
+	if v := values.Get("batchSize"); v != "" {
+		batchSize, err := strconv.Atoi(v)
+		if err != nil {
+			return validate.NewFieldsError("batchSize", err)
+		}
+		cfg.BatchSize = batchSize
+	}
+
+	body, err := web.Body(r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	rows, err := user.DecodeImport(body, r.Header.Get("Content-Type"), user.MaxImportRows)
+	if err != nil {
+		if errors.Is(err, web.ErrBodyTooLarge) || errors.Is(err, web.ErrInvalidEncoding) {
+			return err
+		}
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	report, err := h.User.Import(ctx, rows, cfg)
+	if err != nil {
+		if errors.Is(err, user.ErrUniqueEmail) {
+			return v1Web.NewRequestError(user.ErrUniqueEmail, http.StatusConflict)
+		}
+		return fmt.Errorf("import: %w", err)
+	}
+
+	return web.Respond(ctx, w, report, http.StatusOK)
+}
+
+// Update updates a user in the system. The client must send the ETag it got
+// for the user in the If-Match header. A stale ETag gets a 412 carrying the
+// current one, the client must fetch the user again before retrying.
 func (h *Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	var upd user.UpdateUser
-	if err := web.Decode(r, &upd); err != nil {
+	var app AppUpdateUser
+	if err := web.Decode(r, &app); err != nil {
 		return fmt.Errorf("unable to decode payload: %w", err)
 	}
 
@@ -68,6 +134,11 @@ func (h *Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Re
 		return auth.NewAuthError("auth failed")
 	}
 
+	upd, err := toCoreUpdateUser(app)
+	if err != nil {
+		return v1Web.NewRequestError(err, http.StatusBadRequest)
+	}
+
 	usr, err := h.User.QueryByID(ctx, userID)
 	if err != nil {
 		switch {
@@ -78,15 +149,29 @@ func (h *Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if err := checkIfMatch(w, r, usr); err != nil {
+		return err
+	}
+
 	usr, err = h.User.Update(ctx, usr, upd)
 	if err != nil {
-		return fmt.Errorf("ID[%s] User[%+v]: %w", userID, &upd, err)
+		switch {
+		case errors.Is(err, user.ErrVersionConflict):
+			return versionConflict(w, err)
+		case errors.Is(err, user.ErrUniqueEmail):
+			return v1Web.NewRequestError(user.ErrUniqueEmail, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] User[%+v]: %w", userID, &upd, err)
+		}
 	}
 
-	return web.Respond(ctx, w, usr, http.StatusOK)
+	setETag(w, usr)
+
+	return web.Respond(ctx, w, toAppUser(usr), http.StatusOK)
 }
 
-// Delete removes a user from the system.
+// Delete removes a user from the system. The client must send the ETag it got
+// for the user in the If-Match header.
 func (h *Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	userID, err := uuid.Parse(web.Param(r, "id"))
 	if err != nil {
@@ -108,13 +193,61 @@ func (h *Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if err := checkIfMatch(w, r, usr); err != nil {
+		return err
+	}
+
 	if err := h.User.Delete(ctx, usr); err != nil {
-		return fmt.Errorf("ID[%s]: %w", userID, err)
+		switch {
+		case errors.Is(err, user.ErrVersionConflict):
+			return versionConflict(w, err)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
 	}
 
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
 
+// Restore brings back a user that was soft deleted.
+func (h *Handlers) Restore(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(web.Param(r, "id"))
+	if err != nil {
+		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+	}
+
+	var filter user.QueryFilter
+	filter.WithUserID(userID)
+	filter.WithIncludeDeleted(true)
+
+	usrs, err := h.User.Query(ctx, filter, []order.By{user.DefaultOrderBy}, 1, 1)
+	if err != nil {
+		return fmt.Errorf("ID[%s]: %w", userID, err)
+	}
+
+	if len(usrs) == 0 {
+		return v1Web.NewRequestError(user.ErrNotFound, http.StatusNotFound)
+	}
+
+	usr, err := h.User.Restore(ctx, usrs[0])
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrVersionConflict):
+			return versionConflict(w, err)
+		case errors.Is(err, user.ErrNotDeleted):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrUniqueEmail):
+			return v1Web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s]: %w", userID, err)
+		}
+	}
+
+	setETag(w, usr)
+
+	return web.Respond(ctx, w, toAppUser(usr), http.StatusOK)
+}
+
 // Query returns a list of users with paging.
 func (h *Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	//page := web.Param(r, "page")
@@ -128,6 +261,11 @@ func (h *Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Req
 	//	return v1Web.NewRequestError(fmt.Errorf("invalid rows format [%s]", rows), http.StatusBadRequest)
 	//}
 
+	// Check the Accept header before running any query.
+	if !web.Acceptable(r.Header.Get("Accept")) {
+		return web.ErrNotAcceptable
+	}
+
 	page, err := paging.ParseRequest(r)
 	if err != nil {
 		return err
@@ -143,6 +281,19 @@ func (h *Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return err
 	}
 
+	set, coreFields, err := parseFields(r)
+	if err != nil {
+		return err
+	}
+	filter.WithFields(coreFields...)
+
+	// Only admins are allowed to see the users that were soft deleted.
+	if filter.IncludeDeleted != nil && *filter.IncludeDeleted {
+		if err := h.authorizeAdmin(ctx, r); err != nil {
+			return err
+		}
+	}
+
 	//users, err := h.User.Query(ctx, pageNumber, rowsPerPage)
 	//if err != nil {
 	//	if errors.Is(err, user.ErrInvalidOrder) {
@@ -150,15 +301,16 @@ func (h *Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Req
 	//	}
 	//	return fmt.Errorf("unable to query for users: %w", err)
 	//}
+	if page.UseCursor {
+		return h.queryByCursor(ctx, w, page, filter, orderBy, set)
+	}
+
 	users, err := h.User.Query(ctx, filter, orderBy, page.Number, page.RowsPerPage)
 	if err != nil {
 		return fmt.Errorf("query: %w", err)
 	}
 
-	items := make([]AppUser, len(users))
-	for i, usr := range users {
-		items[i] = toAppUser(usr)
-	}
+	items := fields.SelectSlice(set, toAppUsers(users))
 
 	total, err := h.User.Count(ctx, filter)
 	if err != nil {
@@ -168,13 +320,81 @@ func (h *Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Req
 	return web.Respond(ctx, w, paging.NewResponse(items, total, page.Number, page.RowsPerPage), http.StatusOK)
 }
 
-// QueryByID returns a user by its ID.
+// queryByCursor returns a list of users using keyset paging.
+func (h *Handlers) queryByCursor(ctx context.Context, w http.ResponseWriter, page paging.Page, filter user.QueryFilter, orderBy []order.By, set fields.Set) error {
+	cursor, err := h.Cursors.Decode(page.Cursor)
+	if err != nil {
+		return err
+	}
+
+	pg, err := h.User.QueryByCursor(ctx, filter, orderBy, cursor, page.RowsPerPage)
+	if err != nil {
+		if errors.Is(err, keyset.ErrInvalidCursor) {
+			return v1Web.NewRequestError(keyset.ErrInvalidCursor, http.StatusBadRequest)
+		}
+		return fmt.Errorf("querybycursor: %w", err)
+	}
+
+	total, err := h.User.Count(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("count: %w", err)
+	}
+
+	next, prev := paging.EncodePage(h.Cursors, pg)
+
+	return web.Respond(ctx, w, paging.NewCursorResponse(fields.SelectSlice(set, toAppUsers(pg.Items)), total, page.RowsPerPage, next, prev), http.StatusOK)
+}
+
+// Export streams every user that matches the filter as NDJSON or CSV,
+// depending on the Accept header. The users are read through a database cursor
+// and flushed to the client as they come, so any number of users can be
+// exported.
+func (h *Handlers) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return err
+	}
+
+	orderBy, err := parseOrder(r)
+	if err != nil {
+		return err
+	}
+
+	set, coreFields, err := parseFields(r)
+	if err != nil {
+		return err
+	}
+	filter.WithFields(coreFields...)
+
+	stream, err := web.NewStream(ctx, w, web.StreamConfig{WriteTimeout: h.WriteTimeout})
+	if err != nil {
+		return err
+	}
+
+	f := func(usr user.User) error {
+		return stream.Write(fields.Select(set, toAppUser(usr)))
+	}
+
+	if err := h.User.QueryEach(ctx, filter, orderBy, f); err != nil {
+		return fmt.Errorf("export: rows[%d]: %w", stream.Rows(), err)
+	}
+
+	return stream.Close()
+}
+
+// QueryByID returns a user by its ID. The version of the user is returned in
+// the ETag header.
 func (h *Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	userID, err := uuid.Parse(web.Param(r, "id"))
 	if err != nil {
 		return v1Web.NewRequestError(ErrInvalidID, http.StatusBadRequest)
 	}
 
+	set, _, err := parseFields(r)
+	if err != nil {
+		return err
+	}
+
 	claims := auth.GetClaims(ctx)
 	if claims.Subject != userID.String() && h.Auth.Authorize(ctx, claims, userID, auth.RuleAdminOnly) != nil {
 		return auth.NewAuthError("auth failed")
@@ -190,7 +410,9 @@ func (h *Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http
 		}
 	}
 
-	return web.Respond(ctx, w, usr, http.StatusOK)
+	setETag(w, usr)
+
+	return web.Respond(ctx, w, fields.Select(set, toAppUser(usr)), http.StatusOK)
 }
 
 // Token provides an API token for the authenticated user.
@@ -242,3 +464,22 @@ func (h *Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// authorizeAdmin makes sure the caller is an authenticated admin. It is used
+// by routes that are public but have options reserved for admins.
+func (h *Handlers) authorizeAdmin(ctx context.Context, r *http.Request) error {
+	claims := auth.GetClaims(ctx)
+	if claims.Subject == "" {
+		var err error
+		claims, err = h.Auth.Authenticate(ctx, r.Header.Get("authorization"))
+		if err != nil {
+			return auth.NewAuthError("authenticate: failed: %s", err)
+		}
+	}
+
+	if err := h.Auth.Authorize(ctx, claims, uuid.UUID{}, auth.RuleAdminOnly); err != nil {
+		return auth.NewAuthError("authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, auth.RuleAdminOnly, err)
+	}
+
+	return nil
+}
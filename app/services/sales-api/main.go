@@ -8,14 +8,21 @@ import (
 	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/debug"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/debug/checkgrp"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/paging"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/alert"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/graceful"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/keystore"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
 	"github.com/ardanlabs/conf/v3"
-	"go.uber.org/zap"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -23,27 +30,51 @@ import (
 var build = "develop"
 
 func main() {
-	// construct the logger
-	log, err := logger.New("SALES-API")
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	traceIDFn := func(ctx context.Context) string {
+		if sc := trace.SpanFromContext(ctx).SpanContext(); sc.TraceID.IsValid() {
+			return sc.TraceID.String()
+		}
+		return ""
+	}
+
+	// An admin can ask for a request to be logged at the debug level.
+	debugFn := func(ctx context.Context) bool {
+		return web.GetValues(ctx).Debug
+	}
+
+	// The alert dispatcher needs the configuration, it's only set once run
+	// parsed it.
+	var alerts atomic.Pointer[alert.Dispatcher]
+
+	events := logger.Events{
+		Error: func(ctx context.Context, r logger.Record) {
+			alerts.Load().Event(ctx, r)
+		},
 	}
-	defer log.Sync()
 
-	if err := run(log); err != nil {
-		log.Errorw("startup", "ERROR", err)
-		log.Sync()
+	// construct the logger
+	log := logger.New(logger.Config{
+		Level:     logger.LevelInfo,
+		Service:   "SALES-API",
+		TraceIDFn: traceIDFn,
+		DebugFn:   debugFn,
+		Events:    events,
+	})
+
+	ctx := context.Background()
+
+	if err := run(ctx, log, &alerts); err != nil {
+		log.Error(ctx, "startup", "ERROR", err)
 		os.Exit(1)
 	}
 }
 
-func run(log *zap.SugaredLogger) error {
+func run(ctx context.Context, log *logger.Logger, alerts *atomic.Pointer[alert.Dispatcher]) error {
 
 	// -------------------------------------------------------------------------
 	// GOMAXPROCS
 
-	log.Infow("startup", "GOMAXPROCS", runtime.GOMAXPROCS(0), "BUILD-", build)
+	log.Info(ctx, "startup", "GOMAXPROCS", runtime.GOMAXPROCS(0), "BUILD-", build)
 
 	// -------------------------------------------------------------------------
 	// Configuration
@@ -59,8 +90,18 @@ func run(log *zap.SugaredLogger) error {
 			WriteTimeout    time.Duration `conf:"default:10s"`
 			IdleTimeout     time.Duration `conf:"default:120s"`
 			ShutdownTimeout time.Duration `conf:"default:20s"`
-			APIHost         string        `conf:"default:0.0.0.0:3000"`
-			DebugHost       string        `conf:"default:0.0.0.0:4000"`
+			// DrainDelay must cover the readiness probe's periodSeconds times
+			// its failureThreshold (zarf/k8s/base/sales/base-sales.yaml), so
+			// the pod is out of the endpoints before the servers stop. With
+			// ShutdownTimeout it must fit in terminationGracePeriodSeconds.
+			DrainDelay         time.Duration `conf:"default:20s"`
+			RequestTimeout     time.Duration `conf:"default:5s"`
+			APIHost            string        `conf:"default:0.0.0.0:3000"`
+			DebugHost          string        `conf:"default:0.0.0.0:4000"`
+			CursorKey          string        `conf:"mask"`
+			IdempotencyTTL     time.Duration `conf:"default:24h"`
+			MaxBodyBytes       int64         `conf:"default:4194304"`
+			CORSAllowedOrigins []string
 		}
 		DB struct {
 			User     string `conf:"default:postgres"`
@@ -75,12 +116,39 @@ func run(log *zap.SugaredLogger) error {
 			MaxIdleConns int    `conf:"default:2"`
 			MaxOpenConns int    `conf:"default:0"`
 			DisableTLS   bool   `conf:"default:true"`
+
+			// SlowQueryThreshold is how long a query runs before it's
+			// logged as slow, zero turns the log off.
+			SlowQueryThreshold time.Duration `conf:"default:200ms"`
+		}
+		Log struct {
+			// Level can be changed later through /debug/loglevel.
+			Level string `conf:"default:info"`
 		}
 		Auth struct {
 			KeysFolder string `conf:"default:zarf/keys/"`
 			ActiveKID  string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
 			Issuer     string `conf:"default:service project"`
 		}
+		RateLimit struct {
+			Users string `conf:"default:100/1m"`
+			Token string `conf:"default:10/1m"`
+		}
+		Alert struct {
+			// Alerts are posted to every webhook and appended to the
+			// file, none are raised when both are empty.
+			WebhookURLs  []string `conf:"mask"`
+			File         string
+			DedupeWindow time.Duration `conf:"default:5m"`
+			Limit        string        `conf:"default:10/1m"`
+		}
+		Trace struct {
+			// An empty URI turns exporting off, trace ids are still
+			// propagated.
+			ReporterURI string
+			ServiceName string  `conf:"default:sales-api"`
+			Probability float64 `conf:"default:0.05"`
+		}
 	}{
 		Version: conf.Version{
 			Build: build,
@@ -100,11 +168,17 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("parsing config: %w", err)
 	}
 
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return fmt.Errorf("parsing log level: %w", err)
+	}
+	log.SetLevel(level)
+
 	// -------------------------------------------------------------------------
 	// App Starting
 
-	log.Infow("starting service", "version", build)
-	defer log.Infow("shutdown complete")
+	log.Info(ctx, "starting service", "version", build)
+	defer log.Info(ctx, "shutdown complete")
 
 	out, err := conf.String(&cfg)
 	if err != nil {
@@ -112,12 +186,62 @@ func run(log *zap.SugaredLogger) error {
 	}
 
 	// show the config that you're running
-	log.Infow("startup", "config", out)
+	log.Info(ctx, "startup", "config", out)
+
+	// -------------------------------------------------------------------------
+	// Alerting Support
+
+	log.Info(ctx, "startup", "status", "initializing alerting support", "webhooks", len(cfg.Alert.WebhookURLs), "file", cfg.Alert.File)
+
+	var sinks []alert.Sink
+	for _, url := range cfg.Alert.WebhookURLs {
+		sinks = append(sinks, alert.NewWebhook(url))
+	}
+
+	if cfg.Alert.File != "" {
+		file, err := alert.NewFile(cfg.Alert.File)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		sinks = append(sinks, file)
+	}
+
+	if len(sinks) > 0 {
+		alertLimit, err := ratelimit.ParseLimit(cfg.Alert.Limit)
+		if err != nil {
+			return fmt.Errorf("parsing alert limit: %w", err)
+		}
+
+		dispatcher := alert.New(alert.Config{
+			Service:      "SALES-API",
+			Build:        build,
+			DedupeWindow: cfg.Alert.DedupeWindow,
+			Limit:        alertLimit,
+			ErrorHandler: func(err error) {
+				// Not an error, it would raise an alert about itself.
+				log.Warn(ctx, "alert", "status", "sending alert", "ERROR", err)
+			},
+		}, sinks...)
+		alerts.Store(dispatcher)
+
+		defer func() {
+			log.Info(ctx, "shutdown", "status", "stopping alerting support")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := dispatcher.Shutdown(ctx); err != nil {
+				log.Warn(ctx, "shutdown", "status", "stopping alerting support", "ERROR", err)
+			}
+		}()
+	}
 
 	// -------------------------------------------------------------------------
 	// Database Support
 
-	log.Infow("startup", "status", "initializing database support", "host", cfg.DB.Host)
+	log.Info(ctx, "startup", "status", "initializing database support", "host", cfg.DB.Host)
 
 	db, err := database.Open(database.Config{
 		User:         cfg.DB.User,
@@ -131,15 +255,16 @@ func run(log *zap.SugaredLogger) error {
 	if err != nil {
 		return fmt.Errorf("connecting to db: %w", err)
 	}
+	database.SetSlowQueryThreshold(cfg.DB.SlowQueryThreshold)
 	defer func() {
-		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DB.Host)
+		log.Info(ctx, "shutdown", "status", "stopping database support", "host", cfg.DB.Host)
 		db.Close()
 	}()
 
 	// -------------------------------------------------------------------------
 	// Initialize authentication support
 
-	log.Infow("startup", "status", "initializing authentication support")
+	log.Info(ctx, "startup", "status", "initializing authentication support")
 
 	// Simple keystore versus using Vault.
 	ks, err := keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder))
@@ -166,38 +291,114 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("constructing auth: %w", err)
 	}
 
+	// -------------------------------------------------------------------------
+	// Start Tracing Support
+
+	log.Info(ctx, "startup", "status", "initializing tracing support", "reporter", cfg.Trace.ReporterURI)
+
+	var tracer *trace.Tracer
+	if cfg.Trace.ReporterURI != "" {
+		exporter := trace.NewOTLPExporter(trace.OTLPConfig{
+			Endpoint:       cfg.Trace.ReporterURI,
+			ServiceName:    cfg.Trace.ServiceName,
+			ServiceVersion: build,
+		})
+
+		tracer = trace.New(exporter, trace.Config{
+			Probability: cfg.Trace.Probability,
+			ErrorHandler: func(err error) {
+				// Not an error, a collector outage would raise an alert for every batch.
+				log.Warn(ctx, "trace", "status", "exporting spans", "ERROR", err)
+			},
+		})
+
+		defer func() {
+			log.Info(ctx, "shutdown", "status", "stopping tracing support")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := tracer.Shutdown(ctx); err != nil {
+				log.Error(ctx, "shutdown", "status", "stopping tracing support", "ERROR", err)
+			}
+		}()
+	}
+
+	// -------------------------------------------------------------------------
+	// Readiness Checks
+
+	checks := checkgrp.NewRegistry()
+	checks.Register(checkgrp.Check{Name: "database", Critical: true, Func: database.Checker(db)})
+	checks.Register(checkgrp.Check{Name: "keystore", Critical: true, Func: ks.Checker(cfg.Auth.ActiveKID)})
+	checks.Register(checkgrp.Check{Name: "auth", Critical: true, Timeout: 2 * time.Second, Func: auth.Checker()})
+
 	// -------------------------------------------------------------------------
 	// Start Debug Service
 
-	log.Infow("startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
+	log.Info(ctx, "startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
 
 	/* create a goroutine that blocks on a ListenAndServe() call on whatever IP and port is for debug and the second parameter is a mux that
 	registers all of the routes for these handlers.*/
 	// this is an orp
+	/* do not use http.DefaultServeMux here. Some dependency or yourself, could expose some endpoints that shoudldn't be used
+	by anyone and should be behind a firewall, but you accidentally exposed them by binding the http.DefaultServeMux directly.
+	Instead, create your own mux and bind that here instead of http.DefaultServeMux.*/
+	/* debug.Mux() registers the /debug routes which include of pprof routes and readiness and liveness handlers. */
+	debugSrv := http.Server{
+		Addr:     cfg.Web.DebugHost,
+		Handler:  debug.Mux(build, log, db, checks),
+		ErrorLog: logger.NewStdLogger(log, logger.LevelError),
+	}
+
 	go func() {
-		/* do not use http.DefaultServeMux here. Some dependency or yourself, could expose some endpoints that shoudldn't be used
-		by anyone and should be behind a firewall, but you accidentally exposed them by binding the http.DefaultServeMux directly.
-		Instead, create your own mux and bind that here instead of http.DefaultServeMux.*/
-		/* debug.Mux() registers the /debug routes which include of pprof routes and readiness and liveness handlers. */
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db)); err != nil {
-			log.Errorw("shutdown", "status", "debug v1 router closed", "host", cfg.Web.DebugHost, "msg", err)
+		if err := debugSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ctx, "shutdown", "status", "debug v1 router closed", "host", cfg.Web.DebugHost, "msg", err)
 		}
 	}()
 
 	// -------------------------------------------------------------------------
 	// Start API Service
 
-	log.Infow("startup", "status", "initializing V1 API support")
+	log.Info(ctx, "startup", "status", "initializing V1 API support")
 
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
-	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Shutdown: shutdown,
-		Log:      log,
-		Auth:     auth,
-		DB:       db,
+	usersLimit, err := ratelimit.ParseLimit(cfg.RateLimit.Users)
+	if err != nil {
+		return fmt.Errorf("parsing users rate limit: %w", err)
+	}
+
+	tokenLimit, err := ratelimit.ParseLimit(cfg.RateLimit.Token)
+	if err != nil {
+		return fmt.Errorf("parsing token rate limit: %w", err)
+	}
+
+	// The key has no default, every deployment must sign with its own.
+	cursors, err := paging.NewCursors([]byte(cfg.Web.CursorKey))
+	if err != nil {
+		return fmt.Errorf("constructing cursors: %w", err)
+	}
+
+	apiMux, err := handlers.APIMux(handlers.APIMuxConfig{
+		Shutdown:           shutdown,
+		Log:                log,
+		Auth:               auth,
+		DB:                 db,
+		Cursors:            cursors,
+		WriteTimeout:       cfg.Web.WriteTimeout,
+		RequestTimeout:     cfg.Web.RequestTimeout,
+		IdempotencyTTL:     cfg.Web.IdempotencyTTL,
+		RateLimits:         ratelimit.NewMemory(),
+		UsersLimit:         usersLimit,
+		TokenLimit:         tokenLimit,
+		MaxBodyBytes:       cfg.Web.MaxBodyBytes,
+		CORSAllowedOrigins: cfg.Web.CORSAllowedOrigins,
+		Tracer:             tracer,
 	})
+	if err != nil {
+		return fmt.Errorf("constructing api mux: %w", err)
+	}
 
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
@@ -205,13 +406,13 @@ func run(log *zap.SugaredLogger) error {
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 		IdleTimeout:  cfg.Web.IdleTimeout,
-		ErrorLog:     zap.NewStdLog(log.Desugar()),
+		ErrorLog:     logger.NewStdLogger(log, logger.LevelError),
 	}
 
 	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Infow("startup", "status", "api router started", "host", api.Addr)
+		log.Info(ctx, "startup", "status", "api router started", "host", api.Addr)
 		serverErrors <- api.ListenAndServe()
 	}()
 
@@ -223,20 +424,36 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("server error: %w", err)
 
 	case sig := <-shutdown:
-		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
-		defer log.Infow("shutdown", "status", "shutdown completed", "signal", sig)
+		log.Info(ctx, "shutdown", "status", "shutdown started", "signal", sig)
+		defer log.Info(ctx, "shutdown", "status", "shutdown completed", "signal", sig)
 
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
+		// A second signal cuts the drain delay short.
+		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
+		go func() {
+			select {
+			case <-shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
 		// -------------------------------------------------------------------------
 		// LOAD SHEDDING FOR APPLICATION API
-		/* tell the api to start load shedding and we give this operation a timeout using ctx. We give the api some time to make sure
-		that all the goroutines terminate, but this can go on forever, so we give it a timeout.*/
-		if err := api.Shutdown(ctx); err != nil {
-			api.Close()
-
-			return fmt.Errorf("could not stop server gracefully: %w", err)
+		/* fail readiness first so Kubernetes stops routing traffic to us, keep serving for the drain delay and only then tell
+		the api to start load shedding. The servers are given ShutdownTimeout to make sure that all the goroutines terminate.*/
+		coordinator := graceful.New(graceful.Config{
+			Log:        log,
+			DrainDelay: cfg.Web.DrainDelay,
+			Timeout:    cfg.Web.ShutdownTimeout,
+		})
+		coordinator.OnDrain(checks.Drain)
+		coordinator.AddServer("api", &api)
+		coordinator.AddServer("debug", &debugSrv)
+
+		if err := coordinator.Shutdown(ctx); err != nil {
+			return err
 		}
 	}
 
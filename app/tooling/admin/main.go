@@ -2,10 +2,21 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency/stores/idempotencydb"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user/stores/userdb"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbmigrate"
 	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -26,6 +37,31 @@ func run() error {
 		DisableTLS:   true,
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		retention := 30 * 24 * time.Hour
+		if len(os.Args) > 2 {
+			var err error
+			retention, err = time.ParseDuration(os.Args[2])
+			if err != nil {
+				return fmt.Errorf("parsing retention: %w", err)
+			}
+		}
+
+		if err := purge(cfg, retention); err != nil {
+			return fmt.Errorf("purge: %w", err)
+		}
+
+		return nil
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := importUsers(cfg, os.Args[2:]); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+
+		return nil
+	}
+
 	if err := migrate(cfg); err != nil {
 		return fmt.Errorf("migrate: %w", err)
 	}
@@ -72,3 +108,109 @@ func seed(cfg database.Config) error {
 	fmt.Println("seed data complete")
 	return nil
 }
+
+// purge permanently removes the users that were soft deleted longer ago than
+// the retention period, along with the expired idempotency keys. Usage: admin purge 720h
+func purge(cfg database.Config, retention time.Duration) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	log := logger.New(logger.Config{Service: "ADMIN"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	if err := core.Purge(ctx, retention); err != nil {
+		return fmt.Errorf("purge users: %w", err)
+	}
+
+	idemCore := idempotency.NewCore(idempotencydb.NewStore(log, db))
+
+	if err := idemCore.PurgeExpired(ctx); err != nil {
+		return fmt.Errorf("purge idempotency keys: %w", err)
+	}
+
+	fmt.Println("purge complete, retention:", retention)
+	return nil
+}
+
+// importUsers adds the users of a CSV or JSON file to the system and prints
+// the report. Usage: admin import [-dry-run] [-mode batch|row] [-batch-size 100] [-max-rows 5000] users.csv
+func importUsers(cfg database.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "validate the rows without inserting them")
+	mode := fs.String("mode", user.ImportModeBatch, "insert all rows in one transaction (batch) or each on its own (row)")
+	batchSize := fs.Int("batch-size", 100, "number of users inserted per statement in batch mode")
+	maxRows := fs.Int("max-rows", 5000, "maximum number of rows in the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: admin import [-dry-run] [-mode batch|row] [-batch-size 100] [-max-rows 5000] <file.csv|file.json>")
+	}
+	path := fs.Arg(0)
+
+	var contentType string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		contentType = "text/csv"
+	case ".json":
+		contentType = "application/json"
+	default:
+		return fmt.Errorf("unsupported file type %q", filepath.Ext(path))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	rows, err := user.DecodeImport(f, contentType, *maxRows)
+	if err != nil {
+		return fmt.Errorf("decode file: %w", err)
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	log := logger.New(logger.Config{Service: "ADMIN"})
+
+	// Hashing a password takes close to 100ms, allow twice that per row.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute+time.Duration(len(rows))*200*time.Millisecond)
+	defer cancel()
+
+	core := user.NewCore(userdb.NewStore(log, db))
+
+	importCfg := user.ImportConfig{
+		DryRun:    *dryRun,
+		Mode:      *mode,
+		BatchSize: *batchSize,
+	}
+
+	report, err := core.Import(ctx, rows, importCfg)
+	if err != nil {
+		return fmt.Errorf("import users: %w", err)
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	fmt.Println(string(out))
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d rows failed", len(report.Errors))
+	}
+
+	return nil
+}
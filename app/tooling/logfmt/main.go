@@ -44,7 +44,7 @@ func main() {
 		}
 
 		// I like always having a traceid present in the logs.
-		traceID := "00000000-0000-0000-0000-000000000000"
+		traceID := "00000000000000000000000000000000"
 		if v, ok := m["trace_id"]; ok {
 			traceID = fmt.Sprintf("%v", v)
 		}
@@ -56,10 +56,10 @@ func main() {
 		b.Reset()
 		b.WriteString(fmt.Sprintf("%s: %s: %s: %s: %s: %s: ",
 			m["service"],
-			m["ts"],
+			m["time"],
 			m["level"],
 			traceID,
-			m["caller"],
+			m["file"],
 			m["msg"],
 		))
 
@@ -67,7 +67,7 @@ func main() {
 		// added for the log.
 		for k, v := range m {
 			switch k {
-			case "service", "ts", "level", "trace_id", "caller", "msg":
+			case "service", "time", "level", "trace_id", "file", "msg":
 				continue
 			}
 
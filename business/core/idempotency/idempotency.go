@@ -0,0 +1,132 @@
+// Package idempotency provides support for replaying the response of a request
+// that is retried with the same idempotency key.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Set of error variables for CRUD operations.
+var (
+	ErrNotFound   = errors.New("idempotency key not found")
+	ErrExists     = errors.New("idempotency key already exists")
+	ErrKeyReused  = errors.New("idempotency key was already used with a different request")
+	ErrInProgress = errors.New("a request with the same idempotency key is in progress")
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve data.
+type Storer interface {
+	Create(ctx context.Context, rec Record) error
+	Update(ctx context.Context, rec Record) error
+	Delete(ctx context.Context, key string, subject string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+	QueryByKey(ctx context.Context, key string, subject string) (Record, error)
+}
+
+// Core manages the set of APIs for idempotency key access.
+type Core struct {
+	storer Storer
+}
+
+// NewCore constructs a core for idempotency key api access.
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Begin claims the key for the subject. A pending record is returned when the
+// request can be processed. A completed record is returned when the request
+// was already processed and its response must be replayed. ErrKeyReused is
+// returned when the key was used with a different request and ErrInProgress
+// when the first request with the key hasn't completed yet. The claim expires
+// after the lease, so a request that never completed, like when the process
+// crashed, doesn't hold the key until the ttl of a response.
+func (c *Core) Begin(ctx context.Context, key string, subject string, requestHash string, lease time.Duration) (Record, error) {
+	now := time.Now()
+
+	rec := Record{
+		Key:         key,
+		Subject:     subject,
+		RequestHash: requestHash,
+		DateCreated: now,
+		DateExpires: now.Add(lease),
+	}
+
+	// The record of an expired key is removed and the key claimed again, this
+	// only needs to happen once.
+	for attempt := 0; attempt < 2; attempt++ {
+		err := c.storer.Create(ctx, rec)
+		if err == nil {
+			return rec, nil
+		}
+
+		if !errors.Is(err, ErrExists) {
+			return Record{}, fmt.Errorf("create: %w", err)
+		}
+
+		stored, err := c.storer.QueryByKey(ctx, key, subject)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return Record{}, fmt.Errorf("query: key[%s]: %w", key, err)
+		}
+
+		if stored.IsExpired(now) {
+			if err := c.storer.Delete(ctx, key, subject); err != nil {
+				return Record{}, fmt.Errorf("delete: key[%s]: %w", key, err)
+			}
+			continue
+		}
+
+		switch {
+		case stored.RequestHash != requestHash:
+			return Record{}, ErrKeyReused
+		case stored.IsPending():
+			return Record{}, ErrInProgress
+		}
+
+		return stored, nil
+	}
+
+	return Record{}, ErrInProgress
+}
+
+// Complete stores the response of the request that claimed the key so it can
+// be replayed for the ttl.
+func (c *Core) Complete(ctx context.Context, rec Record, ttl time.Duration, statusCode int, header http.Header, body []byte) error {
+	rec.StatusCode = statusCode
+	rec.Header = header
+	rec.Body = body
+	rec.DateExpires = time.Now().Add(ttl)
+
+	if err := c.storer.Update(ctx, rec); err != nil {
+		return fmt.Errorf("update: key[%s]: %w", rec.Key, err)
+	}
+
+	return nil
+}
+
+// Release removes the claim on the key so the request can be retried with it.
+func (c *Core) Release(ctx context.Context, rec Record) error {
+	if err := c.storer.Delete(ctx, rec.Key, rec.Subject); err != nil {
+		return fmt.Errorf("delete: key[%s]: %w", rec.Key, err)
+	}
+
+	return nil
+}
+
+// PurgeExpired removes the records whose time to live has passed.
+func (c *Core) PurgeExpired(ctx context.Context) error {
+	if err := c.storer.DeleteExpired(ctx, time.Now()); err != nil {
+		return fmt.Errorf("delete expired: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+)
+
+// Record represents the stored outcome of a request made with an idempotency
+// key. A record without a status code belongs to a request that is still
+// being processed.
+type Record struct {
+	Key         string
+	Subject     string
+	RequestHash string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	DateCreated time.Time
+	DateExpires time.Time
+}
+
+// IsPending reports whether the request that created the record hasn't
+// completed yet.
+func (r Record) IsPending() bool {
+	return r.StatusCode == 0
+}
+
+// IsExpired reports whether the record has outlived its time to live.
+func (r Record) IsExpired(now time.Time) bool {
+	return !now.Before(r.DateExpires)
+}
@@ -0,0 +1,210 @@
+// Package idempotencydb contains idempotency key related CRUD functionality.
+package idempotencydb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
+	"net/http"
+	"time"
+
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+// Store manages the set of APIs for idempotency key database access.
+type Store struct {
+	log *logger.Logger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *logger.Logger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new record for the key. ErrExists is returned when the key
+// is already claimed by the subject.
+func (s *Store) Create(ctx context.Context, rec idempotency.Record) error {
+	const q = `
+	INSERT INTO idempotency_keys
+		(idempotency_key, subject, request_hash, status_code, header, body, date_created, date_expires)
+	VALUES
+		(:idempotency_key, :subject, :request_hash, :status_code, :header, :body, :date_created, :date_expires)`
+
+	dbRec, err := toDBRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, dbRec); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return idempotency.ErrExists
+		}
+		return fmt.Errorf("inserting record: %w", err)
+	}
+
+	return nil
+}
+
+// Update stores the response and the expiration of the record.
+func (s *Store) Update(ctx context.Context, rec idempotency.Record) error {
+	const q = `
+	UPDATE
+		idempotency_keys
+	SET
+		"status_code" = :status_code,
+		"header" = :header,
+		"body" = :body,
+		"date_expires" = :date_expires
+	WHERE
+		idempotency_key = :idempotency_key AND
+		subject = :subject`
+
+	dbRec, err := toDBRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, dbRec); err != nil {
+		return fmt.Errorf("updating record: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the record of the key.
+func (s *Store) Delete(ctx context.Context, key string, subject string) error {
+	data := struct {
+		Key     string `db:"idempotency_key"`
+		Subject string `db:"subject"`
+	}{
+		Key:     key,
+		Subject: subject,
+	}
+
+	const q = `
+	DELETE FROM
+		idempotency_keys
+	WHERE
+		idempotency_key = :idempotency_key AND
+		subject = :subject`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting record: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the records that expired before now.
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	const q = `
+	DELETE FROM
+		idempotency_keys
+	WHERE
+		date_expires <= :now`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired records: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByKey gets the record of the key claimed by the subject.
+func (s *Store) QueryByKey(ctx context.Context, key string, subject string) (idempotency.Record, error) {
+	data := struct {
+		Key     string `db:"idempotency_key"`
+		Subject string `db:"subject"`
+	}{
+		Key:     key,
+		Subject: subject,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		idempotency_keys
+	WHERE
+		idempotency_key = :idempotency_key AND
+		subject = :subject`
+
+	var dbRec dbRecord
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbRec); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return idempotency.Record{}, idempotency.ErrNotFound
+		}
+		return idempotency.Record{}, fmt.Errorf("selecting key[%q]: %w", key, err)
+	}
+
+	return toCoreRecord(dbRec)
+}
+
+// =============================================================================
+
+// dbRecord represent the structure we need for moving data
+// between the app and the database.
+type dbRecord struct {
+	Key         string    `db:"idempotency_key"`
+	Subject     string    `db:"subject"`
+	RequestHash string    `db:"request_hash"`
+	StatusCode  int       `db:"status_code"`
+	Header      string    `db:"header"`
+	Body        []byte    `db:"body"`
+	DateCreated time.Time `db:"date_created"`
+	DateExpires time.Time `db:"date_expires"`
+}
+
+func toDBRecord(rec idempotency.Record) (dbRecord, error) {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return dbRecord{}, fmt.Errorf("marshal header: %w", err)
+	}
+
+	dbRec := dbRecord{
+		Key:         rec.Key,
+		Subject:     rec.Subject,
+		RequestHash: rec.RequestHash,
+		StatusCode:  rec.StatusCode,
+		Header:      string(header),
+		Body:        rec.Body,
+		DateCreated: rec.DateCreated.UTC(),
+		DateExpires: rec.DateExpires.UTC(),
+	}
+
+	return dbRec, nil
+}
+
+func toCoreRecord(dbRec dbRecord) (idempotency.Record, error) {
+	var header http.Header
+	if err := json.Unmarshal([]byte(dbRec.Header), &header); err != nil {
+		return idempotency.Record{}, fmt.Errorf("unmarshal header: %w", err)
+	}
+
+	rec := idempotency.Record{
+		Key:         dbRec.Key,
+		Subject:     dbRec.Subject,
+		RequestHash: dbRec.RequestHash,
+		StatusCode:  dbRec.StatusCode,
+		Header:      header,
+		Body:        dbRec.Body,
+		DateCreated: dbRec.DateCreated.In(time.Local),
+		DateExpires: dbRec.DateExpires.In(time.Local),
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,101 @@
+package idempotencydb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency/stores/idempotencydb"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbtest"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/docker"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Idempotency(t *testing.T) {
+	log, db, teardown := dbtest.NewUnit(t, c, "testidempotency")
+	defer teardown()
+
+	store := idempotencydb.NewStore(log, db)
+	core := idempotency.NewCore(store)
+
+	ctx := context.Background()
+	const subject = "5cf37266-3473-4006-984f-9325122678b7"
+
+	rec, err := core.Begin(ctx, "k1", subject, "hash", time.Minute)
+	if err != nil {
+		t.Fatalf("Should be able to claim the key : %s", err)
+	}
+	if !rec.IsPending() {
+		t.Fatalf("Should return a pending record : %+v", rec)
+	}
+
+	if _, err := core.Begin(ctx, "k1", subject, "hash", time.Minute); !errors.Is(err, idempotency.ErrInProgress) {
+		t.Fatalf("Should report the request in progress : %v", err)
+	}
+	if _, err := core.Begin(ctx, "k1", subject, "other", time.Minute); !errors.Is(err, idempotency.ErrKeyReused) {
+		t.Fatalf("Should report the key reused with another request : %v", err)
+	}
+
+	// Another subject has its own keys.
+	if _, err := core.Begin(ctx, "k1", "other-subject", "hash", time.Minute); err != nil {
+		t.Fatalf("Should be able to claim the key for another subject : %s", err)
+	}
+
+	header := http.Header{"Location": []string{"/v1/users/1"}}
+	if err := core.Complete(ctx, rec, time.Hour, http.StatusCreated, header, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("Should be able to complete the request : %s", err)
+	}
+
+	stored, err := core.Begin(ctx, "k1", subject, "hash", time.Minute)
+	if err != nil {
+		t.Fatalf("Should be able to get the completed record : %s", err)
+	}
+	if stored.StatusCode != http.StatusCreated || stored.Header.Get("Location") != "/v1/users/1" || string(stored.Body) != `{"id":"1"}` {
+		t.Fatalf("Should return the stored response : %+v", stored)
+	}
+	if stored.DateExpires.Before(time.Now().Add(59 * time.Minute)) {
+		t.Fatalf("Should keep the response for the ttl : expires %s", stored.DateExpires)
+	}
+
+	// A claim whose lease ran out can be taken again.
+	if _, err := core.Begin(ctx, "k2", subject, "hash", -time.Second); err != nil {
+		t.Fatalf("Should be able to claim the key : %s", err)
+	}
+	if _, err := core.Begin(ctx, "k2", subject, "hash", time.Minute); err != nil {
+		t.Fatalf("Should be able to claim the key once the lease ran out : %s", err)
+	}
+
+	rec3, err := core.Begin(ctx, "k3", subject, "hash", time.Minute)
+	if err != nil {
+		t.Fatalf("Should be able to claim the key : %s", err)
+	}
+	if err := core.Release(ctx, rec3); err != nil {
+		t.Fatalf("Should be able to release the key : %s", err)
+	}
+	if _, err := store.QueryByKey(ctx, "k3", subject); !errors.Is(err, idempotency.ErrNotFound) {
+		t.Fatalf("Should remove the released key : %v", err)
+	}
+
+	if err := store.DeleteExpired(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("Should be able to delete the expired records : %s", err)
+	}
+	if _, err := store.QueryByKey(ctx, "k1", subject); !errors.Is(err, idempotency.ErrNotFound) {
+		t.Fatalf("Should delete the expired records : %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package product
 
 import (
 	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 
 	"github.com/google/uuid"
@@ -13,6 +14,26 @@ type QueryFilter struct {
 	Name     *string    `validate:"omitempty,min=3"`
 	Cost     *float64   `validate:"omitempty,numeric"`
 	Quantity *int       `validate:"omitempty,numeric"`
+
+	Conditions []filter.Condition
+}
+
+// Set of fields that can be used in filter conditions.
+const (
+	FilterByProdID   = "product_id"
+	FilterByName     = "name"
+	FilterByCost     = "cost"
+	FilterByQuantity = "quantity"
+	FilterByUserID   = "user_id"
+)
+
+// filterOperators declares the operators each field supports.
+var filterOperators = filter.Allowed{
+	FilterByProdID:   {filter.EQ, filter.NE, filter.IN},
+	FilterByName:     {filter.EQ, filter.NE, filter.IN, filter.LIKE},
+	FilterByCost:     {filter.EQ, filter.NE, filter.GT, filter.GTE, filter.LT, filter.LTE},
+	FilterByQuantity: {filter.EQ, filter.NE, filter.GT, filter.GTE, filter.LT, filter.LTE},
+	FilterByUserID:   {filter.EQ, filter.NE, filter.IN},
 }
 
 // Validate checks the data in the model is considered clean.
@@ -20,9 +41,19 @@ func (qf *QueryFilter) Validate() error {
 	if err := validate.Check(qf); err != nil {
 		return fmt.Errorf("validate: %w", err)
 	}
+
+	if err := filterOperators.Check(qf.Conditions); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
 	return nil
 }
 
+// WithCondition adds a condition to the Conditions field of the QueryFilter value.
+func (qf *QueryFilter) WithCondition(condition filter.Condition) {
+	qf.Conditions = append(qf.Conditions, condition)
+}
+
 // WithProductID sets the ID field of the QueryFilter value.
 func (qf *QueryFilter) WithProductID(productID uuid.UUID) {
 	qf.ID = &productID
@@ -11,8 +11,8 @@ import (
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
 	"time"
 
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/google/uuid"
-	"go.uber.org/zap"
 )
 
 // Set of error variables for CRUD operations.
@@ -26,7 +26,7 @@ type Storer interface {
 	Create(ctx context.Context, prd Product) error
 	Update(ctx context.Context, prd Product) error
 	Delete(ctx context.Context, prd Product) error
-	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]Product, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]Product, error)
 	Count(ctx context.Context, filter QueryFilter) (int, error)
 	QueryByID(ctx context.Context, productID uuid.UUID) (Product, error)
 	QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Product, error)
@@ -34,13 +34,13 @@ type Storer interface {
 
 // Core manages the set of APIs for product access.
 type Core struct {
-	log     *zap.SugaredLogger
+	log     *logger.Logger
 	usrCore *user.Core
 	storer  Storer
 }
 
 // NewCore constructs a core for product api access.
-func NewCore(log *zap.SugaredLogger, usrCore *user.Core, storer Storer) *Core {
+func NewCore(log *logger.Logger, usrCore *user.Core, storer Storer) *Core {
 	core := Core{
 		log:     log,
 		usrCore: usrCore,
@@ -103,7 +103,7 @@ func (c *Core) Delete(ctx context.Context, prd Product) error {
 }
 
 // Query gets all Products from the database.
-func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]Product, error) {
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]Product, error) {
 	prds, err := c.storer.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
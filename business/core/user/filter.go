@@ -1,11 +1,14 @@
 package user
 
 import (
+	"errors"
 	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 	"github.com/google/uuid"
 	"net/mail"
 	"time"
+	"unicode/utf8"
 )
 
 // QueryFilter holds the available fields a query can be filtered on.
@@ -20,6 +23,63 @@ type QueryFilter struct {
 	Email            *mail.Address
 	StartCreatedDate *time.Time
 	EndCreatedDate   *time.Time
+	IncludeDeleted   *bool
+	Conditions       []filter.Condition
+
+	// Fields limits the fields that are loaded for each user, all fields are
+	// loaded when it's empty. Fields that are not loaded hold zero values.
+	Fields []string
+}
+
+// Set of fields a query can be limited to.
+const (
+	FieldID          = "user_id"
+	FieldName        = "name"
+	FieldEmail       = "email"
+	FieldRoles       = "roles"
+	FieldDepartment  = "department"
+	FieldEnabled     = "enabled"
+	FieldDateCreated = "date_created"
+	FieldDateUpdated = "date_updated"
+	FieldDateDeleted = "date_deleted"
+)
+
+var fields = map[string]bool{
+	FieldID:          true,
+	FieldName:        true,
+	FieldEmail:       true,
+	FieldRoles:       true,
+	FieldDepartment:  true,
+	FieldEnabled:     true,
+	FieldDateCreated: true,
+	FieldDateUpdated: true,
+	FieldDateDeleted: true,
+}
+
+// Set of fields that can be used in filter conditions.
+const (
+	FilterByID          = "user_id"
+	FilterByName        = "name"
+	FilterByEmail       = "email"
+	FilterByRoles       = "roles"
+	FilterByDepartment  = "department"
+	FilterByEnabled     = "enabled"
+	FilterByDateCreated = "date_created"
+)
+
+// minNameLike is the shortest value a name LIKE condition takes, so a filter
+// can't match almost every user.
+const minNameLike = 3
+
+// filterOperators declares the operators each field supports.
+var filterOperators = filter.Allowed{
+	FilterByID:          {filter.EQ, filter.NE, filter.IN},
+	FilterByName:        {filter.EQ, filter.NE, filter.IN, filter.LIKE},
+	FilterByEmail:       {filter.EQ, filter.NE, filter.IN, filter.LIKE},
+	FilterByRoles:       {filter.EQ, filter.IN},
+	FilterByDepartment:  {filter.EQ, filter.NE, filter.IN, filter.LIKE},
+	FilterByEnabled:     {filter.EQ, filter.NE},
+	FilterByDateCreated: {filter.EQ, filter.GT, filter.GTE, filter.LT, filter.LTE},
 }
 
 // Validate can perform a check of the data against the validate tags.
@@ -28,9 +88,36 @@ func (qf *QueryFilter) Validate() error {
 		return fmt.Errorf("validate: %w", err)
 	}
 
+	if err := filterOperators.Check(qf.Conditions); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	for _, c := range qf.Conditions {
+		if c.Field != FilterByName || c.Operator != filter.LIKE {
+			continue
+		}
+
+		if v, _ := c.Value.(string); utf8.RuneCountInString(v) < minNameLike {
+			return fmt.Errorf("validate: %w", validate.NewFieldsError(c.Field, fmt.Errorf("must be at least %d characters", minNameLike)))
+		}
+	}
+
+	for _, field := range qf.Fields {
+		if !fields[field] {
+			return fmt.Errorf("validate: %w", validate.NewFieldsError(field, errors.New("field does not exist")))
+		}
+	}
+
 	return nil
 }
 
+// WithCondition adds a condition to the Conditions field of the QueryFilter
+// value. For the roles field, EQ matches users that have the role and IN
+// matches users that have any of the roles.
+func (qf *QueryFilter) WithCondition(condition filter.Condition) {
+	qf.Conditions = append(qf.Conditions, condition)
+}
+
 // WithUserID sets the ID field of the QueryFilter value.
 func (qf *QueryFilter) WithUserID(userID uuid.UUID) {
 	qf.ID = &userID
@@ -57,3 +144,14 @@ func (qf *QueryFilter) WithEndCreatedDate(endDate time.Time) {
 	d := endDate.UTC()
 	qf.EndCreatedDate = &d
 }
+
+// WithIncludeDeleted sets the IncludeDeleted field of the QueryFilter value.
+// By default, soft deleted users are excluded from query results.
+func (qf *QueryFilter) WithIncludeDeleted(includeDeleted bool) {
+	qf.IncludeDeleted = &includeDeleted
+}
+
+// WithFields sets the Fields field of the QueryFilter value.
+func (qf *QueryFilter) WithFields(fields ...string) {
+	qf.Fields = fields
+}
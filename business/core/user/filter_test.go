@@ -0,0 +1,49 @@
+package user_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"testing"
+)
+
+func Test_QueryFilterNameLike(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"empty", "", false},
+		{"one character", "a", false},
+		{"two characters", "bi", false},
+		{"multibyte", "ñé", false},
+		{"minimum", "bil", true},
+		{"longer", "bill", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var qf user.QueryFilter
+			qf.WithCondition(filter.NewCondition(user.FilterByName, filter.LIKE, tt.value))
+
+			err := qf.Validate()
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("Should accept the name %q : %s", tt.value, err)
+				}
+				return
+			}
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 || fe[0].Field != user.FilterByName {
+				t.Fatalf("Should reject the name %q with a field error : %v", tt.value, err)
+			}
+		})
+	}
+
+	var qf user.QueryFilter
+	qf.WithCondition(filter.NewCondition(user.FilterByName, filter.EQ, "a"))
+	if err := qf.Validate(); err != nil {
+		t.Fatalf("Should accept a short name that must match exactly : %s", err)
+	}
+}
@@ -0,0 +1,338 @@
+package user
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// Set of modes an import can insert the users with.
+const (
+	// ImportModeBatch inserts all the users within one transaction, in
+	// batches. Nothing is inserted when a single row is invalid.
+	ImportModeBatch = "batch"
+
+	// ImportModeRow inserts every valid user on its own. Invalid rows are
+	// reported and skipped.
+	ImportModeRow = "row"
+)
+
+// MaxImportRows is the default maximum number of users a single import can
+// contain. Hashing a password at the default bcrypt cost takes close to 100ms,
+// so an import of this size fits within the write timeout of a request. Larger
+// imports go through the admin tool, which sets its own limit.
+const MaxImportRows = 50
+
+// ErrImportTooLarge is returned when an import holds more rows than allowed.
+var ErrImportTooLarge = errors.New("import holds too many rows")
+
+// ImportRow is a user to import, as read from a CSV document or a JSON array.
+type ImportRow struct {
+	Name            string   `json:"name" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
+	Roles           []string `json:"roles" validate:"required"`
+	Department      string   `json:"department"`
+	Password        string   `json:"password" validate:"required"`
+	PasswordConfirm string   `json:"passwordConfirm" validate:"eqfield=Password"`
+}
+
+// ImportConfig represents the settings of an import.
+type ImportConfig struct {
+	DryRun    bool
+	Mode      string
+	BatchSize int
+}
+
+// ImportError holds the validation errors for a single row of an import.
+// Rows are numbered from 1, not counting the CSV header.
+type ImportError struct {
+	Row    int                  `json:"row"`
+	Email  string               `json:"email,omitempty"`
+	Fields validate.FieldErrors `json:"fields"`
+}
+
+// ImportReport is the outcome of an import.
+type ImportReport struct {
+	DryRun  bool          `json:"dryRun"`
+	Mode    string        `json:"mode"`
+	Total   int           `json:"total"`
+	Valid   int           `json:"valid"`
+	Created int           `json:"created"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// DecodeImport reads the users to import from a CSV document or a JSON array,
+// depending on the media type. The CSV header names the columns with the JSON
+// fields of ImportRow, multiple roles are separated by commas. More than
+// maxRows rows return ErrImportTooLarge.
+func DecodeImport(r io.Reader, contentType string, maxRows int) ([]ImportRow, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("parsing content type: %w", err)
+	}
+
+	var rows []ImportRow
+
+	switch mediaType {
+	case "application/json":
+		if err := json.NewDecoder(r).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("decoding json: %w", err)
+		}
+
+	case "text/csv":
+		rows, err = decodeImportCSV(r, maxRows)
+		if err != nil {
+			return nil, fmt.Errorf("decoding csv: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+
+	if len(rows) > maxRows {
+		return nil, fmt.Errorf("%w: limited to %d rows", ErrImportTooLarge, maxRows)
+	}
+
+	return rows, nil
+}
+
+func decodeImportCSV(r io.Reader, maxRows int) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	setters := make([]func(*ImportRow, string), len(header))
+	for i, column := range header {
+		switch strings.TrimSpace(column) {
+		case "name":
+			setters[i] = func(row *ImportRow, v string) { row.Name = v }
+		case "email":
+			setters[i] = func(row *ImportRow, v string) { row.Email = v }
+		case "roles":
+			setters[i] = func(row *ImportRow, v string) { row.Roles = splitRoles(v) }
+		case "department":
+			setters[i] = func(row *ImportRow, v string) { row.Department = v }
+		case "password":
+			setters[i] = func(row *ImportRow, v string) { row.Password = v }
+		case "passwordConfirm":
+			setters[i] = func(row *ImportRow, v string) { row.PasswordConfirm = v }
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		var row ImportRow
+		for i, v := range record {
+			setters[i](&row, v)
+		}
+		rows = append(rows, row)
+
+		// Stop reading once the limit is passed, DecodeImport reports it.
+		if len(rows) > maxRows {
+			break
+		}
+	}
+
+	return rows, nil
+}
+
+func splitRoles(v string) []string {
+	var roles []string
+	for _, role := range strings.Split(v, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// =============================================================================
+
+// Import validates every row and, unless it's a dry run, inserts the valid
+// users using the mode of the config. Emails that already exist, or that
+// appear more than once in the import, are reported as row errors.
+func (c *Core) Import(ctx context.Context, rows []ImportRow, cfg ImportConfig) (ImportReport, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = ImportModeBatch
+	case ImportModeBatch, ImportModeRow:
+	default:
+		return ImportReport{}, validate.NewFieldsError("mode", fmt.Errorf("must be %s or %s", ImportModeBatch, ImportModeRow))
+	}
+
+	report := ImportReport{
+		DryRun: cfg.DryRun,
+		Mode:   cfg.Mode,
+		Total:  len(rows),
+		Errors: []ImportError{},
+	}
+
+	type validRow struct {
+		row int
+		nu  NewUser
+	}
+
+	var valid []validRow
+	seen := make(map[string]int)
+
+	for i, ir := range rows {
+		row := i + 1
+
+		nu, fe := checkImportRow(ir, seen)
+		if fe != nil {
+			report.Errors = append(report.Errors, ImportError{Row: row, Email: ir.Email, Fields: fe})
+			continue
+		}
+
+		seen[strings.ToLower(nu.Email.Address)] = row
+		valid = append(valid, validRow{row: row, nu: nu})
+	}
+
+	// Look the emails up at once instead of once per row.
+	if len(valid) > 0 {
+		emails := make([]mail.Address, len(valid))
+		for i, v := range valid {
+			emails[i] = v.nu.Email
+		}
+
+		usrs, err := c.storer.QueryByEmails(ctx, emails)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return ImportReport{}, fmt.Errorf("import: query by emails: %w", err)
+		}
+
+		exists := make(map[string]bool, len(usrs))
+		for _, usr := range usrs {
+			exists[strings.ToLower(usr.Email.Address)] = true
+		}
+
+		n := 0
+		for _, v := range valid {
+			if exists[strings.ToLower(v.nu.Email.Address)] {
+				fe := validate.FieldErrors{{Field: "email", Err: ErrUniqueEmail.Error()}}
+				report.Errors = append(report.Errors, ImportError{Row: v.row, Email: v.nu.Email.Address, Fields: fe})
+				continue
+			}
+			valid[n] = v
+			n++
+		}
+		valid = valid[:n]
+	}
+
+	report.Valid = len(valid)
+
+	if cfg.DryRun {
+		return report, nil
+	}
+
+	switch cfg.Mode {
+	case ImportModeBatch:
+		if len(report.Errors) > 0 {
+			return report, nil
+		}
+
+		nus := make([]NewUser, len(valid))
+		for i, v := range valid {
+			nus[i] = v.nu
+		}
+
+		usrs, err := c.CreateBatch(ctx, nus, cfg.BatchSize)
+		if err != nil {
+			return ImportReport{}, fmt.Errorf("import: %w", err)
+		}
+		report.Created = len(usrs)
+
+	case ImportModeRow:
+		for _, v := range valid {
+			if err := ctx.Err(); err != nil {
+				return ImportReport{}, fmt.Errorf("import: row[%d]: %w", v.row, err)
+			}
+
+			if _, err := c.Create(ctx, v.nu); err != nil {
+				if !errors.Is(err, ErrUniqueEmail) {
+					return ImportReport{}, fmt.Errorf("import: row[%d]: %w", v.row, err)
+				}
+
+				fe := validate.FieldErrors{{Field: "email", Err: ErrUniqueEmail.Error()}}
+				report.Errors = append(report.Errors, ImportError{Row: v.row, Email: v.nu.Email.Address, Fields: fe})
+				continue
+			}
+			report.Created++
+		}
+	}
+
+	return report, nil
+}
+
+// checkImportRow validates a row and converts it to a new user. The seen map
+// holds the emails of the rows that were accepted so far.
+func checkImportRow(ir ImportRow, seen map[string]int) (NewUser, validate.FieldErrors) {
+	if err := validate.Check(ir); err != nil {
+		if fe := validate.GetFieldErrors(err); fe != nil {
+			return NewUser{}, fe
+		}
+		return NewUser{}, validate.FieldErrors{{Field: "row", Err: err.Error()}}
+	}
+
+	var fe validate.FieldErrors
+
+	roles := make([]Role, len(ir.Roles))
+	for i, roleStr := range ir.Roles {
+		role, err := ParseRole(roleStr)
+		if err != nil {
+			fe = append(fe, validate.FieldError{Field: "roles", Err: err.Error()})
+			break
+		}
+		roles[i] = role
+	}
+
+	addr, err := mail.ParseAddress(ir.Email)
+	if err != nil {
+		fe = append(fe, validate.FieldError{Field: "email", Err: err.Error()})
+		return NewUser{}, fe
+	}
+
+	if row, exists := seen[strings.ToLower(addr.Address)]; exists {
+		fe = append(fe, validate.FieldError{Field: "email", Err: fmt.Sprintf("duplicates the email of row %d", row)})
+		return NewUser{}, fe
+	}
+
+	if len(fe) > 0 {
+		return NewUser{}, fe
+	}
+
+	nu := NewUser{
+		Name:            ir.Name,
+		Email:           *addr,
+		Roles:           roles,
+		Department:      ir.Department,
+		Password:        ir.Password,
+		PasswordConfirm: ir.PasswordConfirm,
+	}
+
+	return nu, nil
+}
@@ -0,0 +1,190 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func Test_DecodeImport(t *testing.T) {
+	const csvDoc = "name,email,roles,department,password,passwordConfirm\n" +
+		"Bill Kennedy,bill@ardanlabs.com,\"ADMIN,USER\",ops,gophers,gophers\n" +
+		"Ale Kennedy,ale@ardanlabs.com,USER,,gophers,gophers\n"
+
+	rows, err := user.DecodeImport(strings.NewReader(csvDoc), "text/csv; charset=utf-8", 10)
+	if err != nil {
+		t.Fatalf("Should be able to decode the csv : %s", err)
+	}
+	if len(rows) != 2 || rows[0].Email != "bill@ardanlabs.com" || len(rows[0].Roles) != 2 || rows[0].Department != "ops" {
+		t.Fatalf("Should decode every column of the csv : %+v", rows)
+	}
+
+	const jsonDoc = `[{"name":"Bill Kennedy","email":"bill@ardanlabs.com","roles":["USER"],"password":"gophers","passwordConfirm":"gophers"}]`
+
+	rows, err = user.DecodeImport(strings.NewReader(jsonDoc), "application/json", 10)
+	if err != nil {
+		t.Fatalf("Should be able to decode the json : %s", err)
+	}
+	if len(rows) != 1 || rows[0].Roles[0] != "USER" {
+		t.Fatalf("Should decode the json : %+v", rows)
+	}
+
+	if _, err := user.DecodeImport(strings.NewReader("name,phone\nBill,555\n"), "text/csv", 10); err == nil {
+		t.Fatalf("Should reject an unknown column.")
+	}
+
+	if _, err := user.DecodeImport(strings.NewReader(csvDoc), "text/csv", 1); !errors.Is(err, user.ErrImportTooLarge) {
+		t.Fatalf("Should reject an import over the row limit : %v", err)
+	}
+
+	if _, err := user.DecodeImport(strings.NewReader(csvDoc), "text/plain", 10); err == nil {
+		t.Fatalf("Should reject an unsupported content type.")
+	}
+}
+
+// importStore is a Storer that knows the existing emails and counts the
+// created users, the methods an import doesn't use panic.
+type importStore struct {
+	user.Storer
+	existing []string
+	created  int
+	lookups  int
+}
+
+func (s *importStore) WithinTran(ctx context.Context, fn func(user.Storer) error) error {
+	return fn(s)
+}
+
+func (s *importStore) Create(ctx context.Context, usr user.User) error {
+	s.created++
+	return nil
+}
+
+func (s *importStore) CreateBatch(ctx context.Context, usrs []user.User) error {
+	s.created += len(usrs)
+	return nil
+}
+
+func (s *importStore) QueryByEmails(ctx context.Context, emails []mail.Address) ([]user.User, error) {
+	s.lookups++
+
+	var usrs []user.User
+	for _, email := range emails {
+		for _, existing := range s.existing {
+			if email.Address == existing {
+				usrs = append(usrs, user.User{Email: email})
+			}
+		}
+	}
+
+	if len(usrs) == 0 {
+		return nil, user.ErrNotFound
+	}
+
+	return usrs, nil
+}
+
+func Test_Import(t *testing.T) {
+	newRow := func(email string) user.ImportRow {
+		return user.ImportRow{
+			Name:            "Bill Kennedy",
+			Email:           email,
+			Roles:           []string{"USER"},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
+		}
+	}
+
+	invalid := newRow("bill@ardanlabs.com")
+	invalid.Roles = []string{"OWNER"}
+
+	rows := []user.ImportRow{
+		newRow("bill@ardanlabs.com"),
+		newRow("ale@ardanlabs.com"),
+		newRow("Bill@ardanlabs.com"),
+		newRow("jack@ardanlabs.com"),
+		invalid,
+	}
+
+	tests := []struct {
+		name    string
+		cfg     user.ImportConfig
+		valid   int
+		created int
+	}{
+		{"dry run", user.ImportConfig{DryRun: true, Mode: user.ImportModeBatch}, 2, 0},
+		{"batch", user.ImportConfig{Mode: user.ImportModeBatch}, 2, 0},
+		{"row", user.ImportConfig{Mode: user.ImportModeRow}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := importStore{existing: []string{"jack@ardanlabs.com"}}
+			core := user.NewCore(&store)
+
+			report, err := core.Import(context.Background(), rows, tt.cfg)
+			if err != nil {
+				t.Fatalf("Should be able to import : %s", err)
+			}
+
+			if report.Total != 5 || report.Valid != tt.valid || report.Created != tt.created || store.created != tt.created {
+				t.Fatalf("Should report %d valid and %d created : %+v", tt.valid, tt.created, report)
+			}
+			if store.lookups != 1 {
+				t.Fatalf("Should look the emails up at once : got %d lookups", store.lookups)
+			}
+
+			exp := map[int]string{3: "email", 4: "email", 5: "roles"}
+			if len(report.Errors) != len(exp) {
+				t.Fatalf("Should report %d row errors : %+v", len(exp), report.Errors)
+			}
+			for _, ie := range report.Errors {
+				if len(ie.Fields) == 0 || ie.Fields[0].Field != exp[ie.Row] {
+					t.Fatalf("Should report row %d on %s : %+v", ie.Row, exp[ie.Row], ie)
+				}
+			}
+		})
+	}
+
+	t.Run("batch without errors", func(t *testing.T) {
+		store := importStore{}
+		core := user.NewCore(&store)
+
+		report, err := core.Import(context.Background(), rows[:2], user.ImportConfig{BatchSize: 1})
+		if err != nil {
+			t.Fatalf("Should be able to import : %s", err)
+		}
+		if report.Mode != user.ImportModeBatch || report.Created != 2 || store.created != 2 {
+			t.Fatalf("Should create the users in a batch : %+v", report)
+		}
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		core := user.NewCore(&importStore{})
+
+		_, err := core.Import(context.Background(), rows, user.ImportConfig{Mode: "all"})
+		if !validate.IsFieldErrors(err) {
+			t.Fatalf("Should reject an unknown mode with a field error : %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		store := importStore{}
+		core := user.NewCore(&store)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := core.Import(ctx, rows[:2], user.ImportConfig{})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Should stop hashing once the context is done : %v", err)
+		}
+		if store.created != 0 {
+			t.Fatalf("Should not create any user : got %d", store.created)
+		}
+	})
+}
@@ -18,6 +18,16 @@ type User struct {
 	Enabled      bool
 	DateCreated  time.Time
 	DateUpdated  time.Time
+	DateDeleted  time.Time
+
+	// Version is incremented on every change and is used as an optimistic
+	// lock so concurrent updates don't silently overwrite each other.
+	Version int
+}
+
+// IsDeleted reports whether the user has been soft deleted.
+func (u User) IsDeleted() bool {
+	return !u.DateDeleted.IsZero()
 }
 
 // NewUser contains information needed to create a new user.
@@ -8,9 +8,10 @@ var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
 // Set of fields that the results can be ordered by.
 // Instead of iota, we use strings because they're more readable. Integer values for these constants are not readable.
 const (
-	OrderByID      = "user_id"
-	OrderByName    = "name"
-	OrderByEmail   = "email"
-	OrderByRoles   = "roles"
-	OrderByEnabled = "enabled"
+	OrderByID         = "user_id"
+	OrderByName       = "name"
+	OrderByEmail      = "email"
+	OrderByRoles      = "roles"
+	OrderByEnabled    = "enabled"
+	OrderByDepartment = "department"
 )
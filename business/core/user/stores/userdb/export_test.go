@@ -0,0 +1,30 @@
+package userdb
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
+)
+
+// ConditionClause exposes conditionClause to the tests.
+var ConditionClause = conditionClause
+
+// Exposes the ordering and keyset paging helpers to the tests.
+var (
+	OrderColumns     = orderColumns
+	RenderOrderBy    = renderOrderBy
+	OrderByClause    = orderByClause
+	KeysetClause     = keysetClause
+	KeysetOrder      = keysetOrder
+	KeysetFilter     = keysetFilter
+	TravelDirections = travelDirections
+)
+
+// CursorPage exposes cursorPage to the tests.
+func CursorPage(rows []string, rowsPerPage int, cursor keyset.Cursor) ([]string, bool, bool) {
+	return cursorPage(rows, rowsPerPage, cursor)
+}
+
+// KeyOf exposes keyOf to the tests.
+func KeyOf(usr user.User, columns []string) []string {
+	return keyOf(toDBUser(usr), columns)
+}
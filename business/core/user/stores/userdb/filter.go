@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx/dbarray"
 	"strings"
+	"time"
 )
 
-func applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *bytes.Buffer) {
+// applyFilter writes the WHERE clause for the filter into buf. Any extra
+// conditions, like the position of a keyset cursor, are ANDed to it.
+func applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *bytes.Buffer, extra ...string) error {
 	var wc []string
 
 	if filter.ID != nil {
@@ -18,7 +23,7 @@ func applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *byte
 	}
 
 	if filter.Name != nil {
-		data["name"] = fmt.Sprintf("%%%s%%", *filter.Name)
+		data["name"] = fmt.Sprintf("%%%s%%", escapeLike(*filter.Name))
 		wc = append(wc, "name LIKE :name")
 	}
 
@@ -37,8 +42,102 @@ func applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *byte
 		wc = append(wc, "date_created <= :end_date_created")
 	}
 
+	if filter.IncludeDeleted == nil || !*filter.IncludeDeleted {
+		wc = append(wc, "date_deleted IS NULL")
+	}
+
+	for i, condition := range filter.Conditions {
+		clause, err := conditionClause(condition, fmt.Sprintf("condition_%d", i), data)
+		if err != nil {
+			return err
+		}
+		wc = append(wc, clause)
+	}
+
+	wc = append(wc, extra...)
+
 	if len(wc) > 0 {
 		buf.WriteString(" WHERE ")
 		buf.WriteString(strings.Join(wc, " AND "))
 	}
+
+	return nil
+}
+
+// =============================================================================
+
+var filterColumns = map[string]string{
+	user.FilterByID:          "user_id",
+	user.FilterByName:        "name",
+	user.FilterByEmail:       "email",
+	user.FilterByRoles:       "roles",
+	user.FilterByDepartment:  "department",
+	user.FilterByEnabled:     "enabled",
+	user.FilterByDateCreated: "date_created",
+}
+
+var comparisons = map[string]string{
+	filter.EQ:  "=",
+	filter.GT:  ">",
+	filter.GTE: ">=",
+	filter.LT:  "<",
+	filter.LTE: "<=",
+}
+
+// conditionClause renders a condition as SQL. The value is always bound to
+// the named parameter, it is never written into the query itself.
+func conditionClause(condition filter.Condition, name string, data map[string]interface{}) (string, error) {
+	column, exists := filterColumns[condition.Field]
+	if !exists {
+		return "", fmt.Errorf("filter field %q does not exist", condition.Field)
+	}
+
+	value := condition.Value
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+
+	// roles is an array column, the conditions check its elements.
+	if column == "roles" {
+		switch condition.Operator {
+		case filter.EQ:
+			data[name] = value
+			return fmt.Sprintf(":%s = ANY(roles)", name), nil
+		case filter.IN:
+			data[name] = dbarray.Array(value)
+			return fmt.Sprintf("roles && CAST(:%s AS TEXT[])", name), nil
+		}
+		return "", fmt.Errorf("operator %q is not supported on roles", condition.Operator)
+	}
+
+	switch condition.Operator {
+	case filter.NE:
+		data[name] = value
+		return fmt.Sprintf("%s IS DISTINCT FROM :%s", column, name), nil
+
+	case filter.IN:
+		data[name] = dbarray.Array(value)
+		return fmt.Sprintf("%s = ANY(:%s)", column, name), nil
+
+	case filter.LIKE:
+		data[name] = fmt.Sprintf("%%%s%%", escapeLike(fmt.Sprint(value)))
+		return fmt.Sprintf("%s LIKE :%s", column, name), nil
+	}
+
+	op, exists := comparisons[condition.Operator]
+	if !exists {
+		return "", fmt.Errorf("operator %q does not exist", condition.Operator)
+	}
+
+	data[name] = value
+	return fmt.Sprintf("%s %s :%s", column, op, name), nil
+}
+
+// likeEscaper escapes the wildcards of LIKE, so a value only matches itself.
+// Backslash is the default escape character of postgres.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike escapes the value to be used within a LIKE pattern.
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
 }
@@ -0,0 +1,83 @@
+package userdb_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user/stores/userdb"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"testing"
+	"time"
+)
+
+func Test_ConditionClause(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	created := time.Date(2023, time.March, 1, 7, 0, 0, 0, est)
+
+	tests := []struct {
+		name      string
+		condition filter.Condition
+		clause    string
+		value     any
+	}{
+		{"eq", filter.NewCondition(user.FilterByEmail, filter.EQ, "bill@ardanlabs.com"), "email = :c", "bill@ardanlabs.com"},
+		{"ne", filter.NewCondition(user.FilterByEnabled, filter.NE, false), "enabled IS DISTINCT FROM :c", false},
+		{"gt", filter.NewCondition(user.FilterByDateCreated, filter.GT, created), "date_created > :c", created.UTC()},
+		{"gte", filter.NewCondition(user.FilterByDateCreated, filter.GTE, created), "date_created >= :c", created.UTC()},
+		{"lt", filter.NewCondition(user.FilterByDateCreated, filter.LT, created), "date_created < :c", created.UTC()},
+		{"lte", filter.NewCondition(user.FilterByDateCreated, filter.LTE, created), "date_created <= :c", created.UTC()},
+		{"like", filter.NewCondition(user.FilterByName, filter.LIKE, "bill"), "name LIKE :c", "%bill%"},
+		{"like wildcards", filter.NewCondition(user.FilterByName, filter.LIKE, `50%_off\`), "name LIKE :c", `%50\%\_off\\%`},
+		{"in", filter.NewCondition(user.FilterByDepartment, filter.IN, []string{"a", "b"}), "department = ANY(:c)", nil},
+		{"roles eq", filter.NewCondition(user.FilterByRoles, filter.EQ, "ADMIN"), ":c = ANY(roles)", "ADMIN"},
+		{"roles in", filter.NewCondition(user.FilterByRoles, filter.IN, []string{"ADMIN", "USER"}), "roles && CAST(:c AS TEXT[])", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(map[string]interface{})
+
+			clause, err := userdb.ConditionClause(tt.condition, "c", data)
+			if err != nil {
+				t.Fatalf("Should be able to render the condition : %s", err)
+			}
+			if clause != tt.clause {
+				t.Fatalf("Should get back the clause : exp %q got %q", tt.clause, clause)
+			}
+			if _, exists := data["c"]; !exists {
+				t.Fatalf("Should bind the value to the named parameter.")
+			}
+
+			// Arrays are wrapped for the driver, only their presence is checked.
+			if tt.value == nil {
+				return
+			}
+			if data["c"] != tt.value {
+				t.Fatalf("Should bind the value : exp %v got %v", tt.value, data["c"])
+			}
+		})
+	}
+}
+
+func Test_ConditionClauseRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition filter.Condition
+	}{
+		{"unknown field", filter.NewCondition("age", filter.EQ, 10)},
+		{"unknown operator", filter.NewCondition(user.FilterByName, "foo", "bill")},
+		{"roles like", filter.NewCondition(user.FilterByRoles, filter.LIKE, "ADMIN")},
+		{"roles ne", filter.NewCondition(user.FilterByRoles, filter.NE, "ADMIN")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(map[string]interface{})
+
+			if _, err := userdb.ConditionClause(tt.condition, "c", data); err == nil {
+				t.Fatalf("Should NOT render the condition.")
+			}
+			if len(data) != 0 {
+				t.Fatalf("Should NOT bind a value : %v", data)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx/dbarray"
 	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +25,49 @@ type dbUser struct {
 	Enabled      bool           `db:"enabled"`
 	DateCreated  time.Time      `db:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated"`
+	DateDeleted  sql.NullTime   `db:"date_deleted"`
+	Version      int            `db:"version"`
+}
+
+var fieldColumns = map[string]string{
+	user.FieldID:          "user_id",
+	user.FieldName:        "name",
+	user.FieldEmail:       "email",
+	user.FieldRoles:       "roles",
+	user.FieldDepartment:  "department",
+	user.FieldEnabled:     "enabled",
+	user.FieldDateCreated: "date_created",
+	user.FieldDateUpdated: "date_updated",
+	user.FieldDateDeleted: "date_deleted",
+}
+
+// selectColumns returns the column list for the fields of the filter. All
+// columns are selected when no fields are requested. The primary key and the
+// columns used for ordering are always selected.
+func selectColumns(fields []string, orderBy []order.By) string {
+	if len(fields) == 0 {
+		return "*"
+	}
+
+	columns := []string{"user_id"}
+	seen := map[string]bool{"user_id": true}
+
+	add := func(column string) {
+		if column != "" && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	for _, field := range fields {
+		add(fieldColumns[field])
+	}
+
+	for _, by := range orderBy {
+		add(orderBySources[by.Field])
+	}
+
+	return strings.Join(columns, ", ")
 }
 
 func toDBUser(usr user.User) dbUser {
@@ -44,6 +89,11 @@ func toDBUser(usr user.User) dbUser {
 		Enabled:     usr.Enabled,
 		DateCreated: usr.DateCreated.UTC(),
 		DateUpdated: usr.DateUpdated.UTC(),
+		DateDeleted: sql.NullTime{
+			Time:  usr.DateDeleted.UTC(),
+			Valid: usr.IsDeleted(),
+		},
+		Version: usr.Version,
 	}
 }
 
@@ -71,6 +121,11 @@ func toCoreUser(dbUsr dbUser) (user.User, error) {
 		Department:   dbUsr.Department.String,
 		DateCreated:  dbUsr.DateCreated.In(time.Local),
 		DateUpdated:  dbUsr.DateUpdated.In(time.Local),
+		Version:      dbUsr.Version,
+	}
+
+	if dbUsr.DateDeleted.Valid {
+		usr.DateDeleted = dbUsr.DateDeleted.Time.In(time.Local)
 	}
 
 	return usr, nil
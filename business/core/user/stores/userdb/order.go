@@ -1,9 +1,16 @@
 package userdb
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 var orderByFields = map[string]string{
@@ -14,13 +21,212 @@ var orderByFields = map[string]string{
 	user.OrderByEmail:   "email",
 	user.OrderByRoles:   "roles",
 	user.OrderByEnabled: "enabled",
+
+	// department is nullable, NULLs can't be compared for keyset paging.
+	user.OrderByDepartment: "COALESCE(department, '')",
+}
+
+// orderBySources maps the order fields to the columns their values are read
+// from. These columns must be selected for keyset paging to work.
+var orderBySources = map[string]string{
+	user.OrderByID:         "user_id",
+	user.OrderByName:       "name",
+	user.OrderByEmail:      "email",
+	user.OrderByRoles:      "roles",
+	user.OrderByEnabled:    "enabled",
+	user.OrderByDepartment: "department",
+}
+
+// orderColumns resolves the list of fields to the columns and directions the
+// query is ordered by. The primary key is always appended as the last column,
+// unless it is already part of the list, so the order is stable and every row
+// has a unique position.
+func orderColumns(orderBy []order.By) ([]string, []string, error) {
+	columns := make([]string, 0, len(orderBy)+1)
+	directions := make([]string, 0, len(orderBy)+1)
+
+	var hasPK bool
+	for _, by := range orderBy {
+		column, exists := orderByFields[by.Field]
+		if !exists {
+			return nil, nil, fmt.Errorf("field %q does not exist", by.Field)
+		}
+
+		columns = append(columns, column)
+		directions = append(directions, by.Direction)
+
+		// Fields after the primary key can't change the order anymore.
+		if column == "user_id" {
+			hasPK = true
+			break
+		}
+	}
+
+	if !hasPK {
+		columns = append(columns, "user_id")
+		directions = append(directions, order.ASC)
+	}
+
+	return columns, directions, nil
+}
+
+func orderByClause(orderBy []order.By) (string, error) {
+	columns, directions, err := orderColumns(orderBy)
+	if err != nil {
+		return "", err
+	}
+
+	return renderOrderBy(columns, directions), nil
 }
 
-func orderByClause(orderBy order.By) (string, error) {
-	by, exists := orderByFields[orderBy.Field]
-	if !exists {
-		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+// renderOrderBy renders the ORDER BY clause for the columns and directions.
+func renderOrderBy(columns []string, directions []string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column + " " + directions[i]
+	}
+
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// =============================================================================
+
+// keysetColumn describes how a column used for ordering takes part in keyset
+// paging: the type the cursor value is cast to and how to read the value from
+// a row.
+type keysetColumn struct {
+	dbType string
+	value  func(dbUsr dbUser) string
+}
+
+var keysetColumns = map[string]keysetColumn{
+	"user_id": {"UUID", func(dbUsr dbUser) string { return dbUsr.ID.String() }},
+	"name":    {"TEXT", func(dbUsr dbUser) string { return dbUsr.Name }},
+	"email":   {"TEXT", func(dbUsr dbUser) string { return dbUsr.Email }},
+	"roles": {"TEXT[]", func(dbUsr dbUser) string {
+		v, _ := dbUsr.Roles.Value()
+		s, _ := v.(string)
+		return s
+	}},
+	"enabled": {"BOOLEAN", func(dbUsr dbUser) string { return strconv.FormatBool(dbUsr.Enabled) }},
+
+	"COALESCE(department, '')": {"TEXT", func(dbUsr dbUser) string { return dbUsr.Department.String }},
+}
+
+// keysetClause builds the condition that selects the rows after the cursor
+// position, given the columns and directions of travel.
+func keysetClause(columns []string, directions []string, key []string, data map[string]interface{}) (string, error) {
+	if len(key) != len(columns) {
+		return "", fmt.Errorf("cursor has %d values, expected %d: %w", len(key), len(columns), keyset.ErrInvalidCursor)
+	}
+
+	ors := make([]string, len(columns))
+	for i := range columns {
+		ands := make([]string, 0, i+1)
+
+		for j := 0; j <= i; j++ {
+			col := keysetColumns[columns[j]]
+			name := fmt.Sprintf("keyset_%d", j)
+			data[name] = key[j]
+
+			op := "="
+			if j == i {
+				op = ">"
+				if directions[j] == order.DESC {
+					op = "<"
+				}
+			}
+
+			ands = append(ands, fmt.Sprintf("%s %s CAST(:%s AS %s)", columns[j], op, name, col.dbType))
+		}
+
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", nil
+}
+
+// keyOf returns the order key of the row for the specified columns.
+func keyOf(dbUsr dbUser, columns []string) []string {
+	key := make([]string, len(columns))
+	for i, column := range columns {
+		key[i] = keysetColumns[column].value(dbUsr)
+	}
+
+	return key
+}
+
+// travelDirections returns the directions the query reads the rows in. Moving
+// backwards is done by flipping the order, the rows are put back in the
+// requested order once they are read.
+func travelDirections(directions []string, prev bool) []string {
+	if !prev {
+		return directions
+	}
+
+	travel := make([]string, len(directions))
+	for i, direction := range directions {
+		travel[i] = order.ASC
+		if direction == order.ASC {
+			travel[i] = order.DESC
+		}
+	}
+
+	return travel
+}
+
+// cursorPage trims the extra row the query asked for, which tells there is
+// more to come, and puts the rows of a backwards query back in order. It
+// returns the rows and whether there are pages after and before them.
+func cursorPage[T any](rows []T, rowsPerPage int, cursor keyset.Cursor) ([]T, bool, bool) {
+	more := len(rows) > rowsPerPage
+	if more {
+		rows = rows[:rowsPerPage]
+	}
+
+	if !cursor.IsPrev() {
+		return rows, more, !cursor.IsStart()
+	}
+
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+
+	// We came back from the next page, so there is one.
+	return rows, true, more
+}
+
+// keysetOrder describes the order of a keyset query for its cursors.
+func keysetOrder(columns []string, directions []string) []string {
+	key := make([]string, len(columns))
+	for i, column := range columns {
+		key[i] = column + " " + directions[i]
+	}
+
+	return key
+}
+
+// keysetFilter returns a hash of the WHERE clause of the filter and the values
+// bound to it, so a cursor can only be used with the filter it was created for.
+func keysetFilter(filter user.QueryFilter) (string, error) {
+	data := make(map[string]interface{})
+
+	var buf bytes.Buffer
+	if err := applyFilter(filter, data, &buf); err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write(buf.Bytes())
+	for _, name := range names {
+		fmt.Fprintf(h, "|%s=%v", name, data[name])
 	}
 
-	return " ORDER BY " + by + " " + orderBy.Direction, nil
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
 }
@@ -0,0 +1,238 @@
+package userdb_test
+
+import (
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user/stores/userdb"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
+	"net/mail"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func Test_OrderColumns(t *testing.T) {
+	tests := []struct {
+		name       string
+		orderBy    []order.By
+		columns    []string
+		directions []string
+	}{
+		{
+			"primary key added", []order.By{order.NewBy(user.OrderByName, order.DESC)},
+			[]string{"name", "user_id"}, []string{order.DESC, order.ASC},
+		},
+		{
+			"several fields", []order.By{order.NewBy(user.OrderByDepartment, order.ASC), order.NewBy(user.OrderByName, order.DESC)},
+			[]string{"COALESCE(department, '')", "name", "user_id"}, []string{order.ASC, order.DESC, order.ASC},
+		},
+		{
+			"primary key only", []order.By{order.NewBy(user.OrderByID, order.DESC)},
+			[]string{"user_id"}, []string{order.DESC},
+		},
+		{
+			"primary key last", []order.By{order.NewBy(user.OrderByEmail, order.ASC), order.NewBy(user.OrderByID, order.DESC)},
+			[]string{"email", "user_id"}, []string{order.ASC, order.DESC},
+		},
+		{
+			"fields after the primary key", []order.By{order.NewBy(user.OrderByID, order.ASC), order.NewBy(user.OrderByName, order.ASC)},
+			[]string{"user_id"}, []string{order.ASC},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, directions, err := userdb.OrderColumns(tt.orderBy)
+			if err != nil {
+				t.Fatalf("Should be able to resolve the order : %s", err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) || !reflect.DeepEqual(directions, tt.directions) {
+				t.Fatalf("Should get back the columns : exp %v %v got %v %v", tt.columns, tt.directions, columns, directions)
+			}
+		})
+	}
+
+	if _, _, err := userdb.OrderColumns([]order.By{order.NewBy("password_hash", order.ASC)}); err == nil {
+		t.Fatalf("Should NOT order by an unknown field.")
+	}
+}
+
+func Test_OrderByClause(t *testing.T) {
+	got, err := userdb.OrderByClause([]order.By{order.NewBy(user.OrderByDepartment, order.ASC), order.NewBy(user.OrderByName, order.DESC)})
+	if err != nil {
+		t.Fatalf("Should be able to render the order : %s", err)
+	}
+
+	if exp := " ORDER BY COALESCE(department, '') ASC, name DESC, user_id ASC"; got != exp {
+		t.Fatalf("Should render the order with the tie-breaker : exp %q got %q", exp, got)
+	}
+
+	if got := userdb.RenderOrderBy([]string{"user_id"}, []string{order.DESC}); got != " ORDER BY user_id DESC" {
+		t.Fatalf("Should render a single column : got %q", got)
+	}
+}
+
+func Test_KeysetClause(t *testing.T) {
+	const id = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	tests := []struct {
+		name       string
+		columns    []string
+		directions []string
+		key        []string
+		clause     string
+	}{
+		{
+			"primary key", []string{"user_id"}, []string{order.ASC}, []string{id},
+			"((user_id > CAST(:keyset_0 AS UUID)))",
+		},
+		{
+			"descending", []string{"name", "user_id"}, []string{order.DESC, order.ASC}, []string{"bill", id},
+			"((name < CAST(:keyset_0 AS TEXT)) OR (name = CAST(:keyset_0 AS TEXT) AND user_id > CAST(:keyset_1 AS UUID)))",
+		},
+		{
+			"column casts", []string{"roles", "enabled", "COALESCE(department, '')", "user_id"}, []string{order.ASC, order.ASC, order.ASC, order.ASC}, []string{"{ADMIN}", "true", "", id},
+			"((roles > CAST(:keyset_0 AS TEXT[])) OR " +
+				"(roles = CAST(:keyset_0 AS TEXT[]) AND enabled > CAST(:keyset_1 AS BOOLEAN)) OR " +
+				"(roles = CAST(:keyset_0 AS TEXT[]) AND enabled = CAST(:keyset_1 AS BOOLEAN) AND COALESCE(department, '') > CAST(:keyset_2 AS TEXT)) OR " +
+				"(roles = CAST(:keyset_0 AS TEXT[]) AND enabled = CAST(:keyset_1 AS BOOLEAN) AND COALESCE(department, '') = CAST(:keyset_2 AS TEXT) AND user_id > CAST(:keyset_3 AS UUID)))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(map[string]interface{})
+
+			clause, err := userdb.KeysetClause(tt.columns, tt.directions, tt.key, data)
+			if err != nil {
+				t.Fatalf("Should be able to build the clause : %s", err)
+			}
+			if clause != tt.clause {
+				t.Fatalf("Should get back the clause :\nexp %s\ngot %s", tt.clause, clause)
+			}
+			for i, v := range tt.key {
+				if name := "keyset_" + string(rune('0'+i)); data[name] != v {
+					t.Fatalf("Should bind %s to %q : got %v", name, v, data[name])
+				}
+			}
+		})
+	}
+}
+
+func Test_KeysetClauseKeySize(t *testing.T) {
+	_, err := userdb.KeysetClause([]string{"name", "user_id"}, []string{order.ASC, order.ASC}, []string{"bill"}, map[string]interface{}{})
+	if !errors.Is(err, keyset.ErrInvalidCursor) {
+		t.Fatalf("Should reject a key that doesn't fit the order : %v", err)
+	}
+}
+
+func Test_TravelDirections(t *testing.T) {
+	directions := []string{order.DESC, order.ASC}
+
+	if got := userdb.TravelDirections(directions, false); !reflect.DeepEqual(got, directions) {
+		t.Fatalf("Should keep the order moving forwards : got %v", got)
+	}
+	if got, exp := userdb.TravelDirections(directions, true), []string{order.ASC, order.DESC}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Should flip the order moving backwards : exp %v got %v", exp, got)
+	}
+	if !reflect.DeepEqual(directions, []string{order.DESC, order.ASC}) {
+		t.Fatalf("Should NOT change the requested directions : got %v", directions)
+	}
+}
+
+func Test_CursorPage(t *testing.T) {
+	start := keyset.Cursor{Direction: keyset.Next}
+	next := keyset.Cursor{Direction: keyset.Next, Key: []string{"b"}}
+	prev := keyset.Cursor{Direction: keyset.Prev, Key: []string{"e"}}
+
+	tests := []struct {
+		name    string
+		rows    []string
+		cursor  keyset.Cursor
+		exp     []string
+		hasNext bool
+		hasPrev bool
+	}{
+		{"first page", []string{"a", "b", "c"}, start, []string{"a", "b"}, true, false},
+		{"only page", []string{"a", "b"}, start, []string{"a", "b"}, false, false},
+		{"empty", nil, start, nil, false, false},
+		{"middle page", []string{"c", "d", "e"}, next, []string{"c", "d"}, true, true},
+		{"last page", []string{"c"}, next, []string{"c"}, false, true},
+		{"previous page", []string{"d", "c", "b"}, prev, []string{"c", "d"}, true, true},
+		{"back to the first page", []string{"d", "c"}, prev, []string{"c", "d"}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, hasNext, hasPrev := userdb.CursorPage(tt.rows, 2, tt.cursor)
+
+			if !reflect.DeepEqual(rows, tt.exp) {
+				t.Fatalf("Should get back the rows in order : exp %v got %v", tt.exp, rows)
+			}
+			if hasNext != tt.hasNext || hasPrev != tt.hasPrev {
+				t.Fatalf("Should tell the pages around : exp %v/%v got %v/%v", tt.hasNext, tt.hasPrev, hasNext, hasPrev)
+			}
+		})
+	}
+}
+
+func Test_KeyOf(t *testing.T) {
+	usr := user.User{
+		ID:      uuid.MustParse("45b5fbd3-755f-4379-8f07-a58d4a30fa2f"),
+		Name:    "Bill",
+		Email:   mail.Address{Address: "bill@ardanlabs.com"},
+		Roles:   []user.Role{user.RoleAdmin, user.RoleUser},
+		Enabled: true,
+	}
+
+	columns := []string{"name", "email", "roles", "enabled", "COALESCE(department, '')", "user_id"}
+	exp := []string{"Bill", "bill@ardanlabs.com", `{"ADMIN","USER"}`, "true", "", "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"}
+
+	if got := userdb.KeyOf(usr, columns); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Should read the order key of the user : exp %q got %q", exp, got)
+	}
+}
+
+func Test_KeysetScope(t *testing.T) {
+	byName := userdb.KeysetOrder([]string{"name", "user_id"}, []string{order.ASC, order.ASC})
+	byEmail := userdb.KeysetOrder([]string{"email", "user_id"}, []string{order.ASC, order.ASC})
+
+	if exp := []string{"name ASC", "user_id ASC"}; !reflect.DeepEqual(byName, exp) {
+		t.Fatalf("Should describe the order : exp %v got %v", exp, byName)
+	}
+
+	var admins user.QueryFilter
+	admins.WithCondition(filter.NewCondition(user.FilterByRoles, filter.EQ, "ADMIN"))
+
+	var users user.QueryFilter
+	users.WithCondition(filter.NewCondition(user.FilterByRoles, filter.EQ, "USER"))
+
+	all, err := userdb.KeysetFilter(user.QueryFilter{})
+	if err != nil {
+		t.Fatalf("Should be able to hash the filter : %s", err)
+	}
+	adminsKey, _ := userdb.KeysetFilter(admins)
+	usersKey, _ := userdb.KeysetFilter(users)
+
+	if again, _ := userdb.KeysetFilter(admins); again != adminsKey {
+		t.Fatalf("Should hash the same filter the same way.")
+	}
+	if all == adminsKey || adminsKey == usersKey {
+		t.Fatalf("Should hash different filters differently.")
+	}
+
+	cur := keyset.Cursor{Direction: keyset.Next, Key: []string{"bill", "1"}, Order: byName, Filter: adminsKey}
+
+	if !cur.Matches(byName, adminsKey) {
+		t.Fatalf("Should match the query the cursor was created for.")
+	}
+	if cur.Matches(byEmail, adminsKey) {
+		t.Fatalf("Should NOT match another order.")
+	}
+	if cur.Matches(byName, usersKey) {
+		t.Fatalf("Should NOT match another filter.")
+	}
+}
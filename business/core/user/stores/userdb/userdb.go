@@ -7,25 +7,30 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
 	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx/dbarray"
 	"net/mail"
+	"time"
 
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 )
 
+// fetchSize is the number of rows read from a cursor at a time.
+const fetchSize = 500
+
 // Store manages the set of APIs for user database access.
 type Store struct {
-	log    *zap.SugaredLogger
+	log    *logger.Logger
 	db     sqlx.ExtContext
 	inTran bool
 }
 
 // NewStore constructs the api for data access.
-func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+func NewStore(log *logger.Logger, db *sqlx.DB) *Store {
 	return &Store{
 		log: log,
 		db:  db,
@@ -54,9 +59,9 @@ func (s *Store) WithinTran(ctx context.Context, fn func(s user.Storer) error) er
 func (s *Store) Create(ctx context.Context, usr user.User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, enabled, date_created, date_updated)
+		(user_id, name, email, password_hash, roles, department, enabled, date_created, date_updated, version)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :enabled, :date_created, :date_updated)`
+		(:user_id, :name, :email, :password_hash, :roles, :department, :enabled, :date_created, :date_updated, :version)`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
@@ -68,7 +73,35 @@ func (s *Store) Create(ctx context.Context, usr user.User) error {
 	return nil
 }
 
-// Update replaces a user document in the database.
+// CreateBatch inserts the users into the database with a single statement.
+func (s *Store) CreateBatch(ctx context.Context, usrs []user.User) error {
+	if len(usrs) == 0 {
+		return nil
+	}
+
+	const q = `
+	INSERT INTO users
+		(user_id, name, email, password_hash, roles, department, enabled, date_created, date_updated, version)
+	VALUES
+		(:user_id, :name, :email, :password_hash, :roles, :department, :enabled, :date_created, :date_updated, :version)`
+
+	dbUsrs := make([]dbUser, len(usrs))
+	for i, usr := range usrs {
+		dbUsrs[i] = toDBUser(usr)
+	}
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, dbUsrs); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("create batch: %w", user.ErrUniqueEmail)
+		}
+		return fmt.Errorf("inserting users: %w", err)
+	}
+
+	return nil
+}
+
+// Update replaces a user document in the database. The row is only updated
+// when its version still matches the version of the provided user.
 func (s *Store) Update(ctx context.Context, usr user.User) error {
 	const q = `
 	UPDATE
@@ -78,11 +111,17 @@ func (s *Store) Update(ctx context.Context, usr user.User) error {
 		"email" = :email,
 		"roles" = :roles,
 		"password_hash" = :password_hash,
-		"date_updated" = :date_updated
+		"department" = :department,
+		"enabled" = :enabled,
+		"date_updated" = :date_updated,
+		"version" = version + 1
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		version = :version
+	RETURNING
+		version`
 
-	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
+	if err := s.execVersioned(ctx, q, usr); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
 			return user.ErrUniqueEmail
 		}
@@ -92,47 +131,101 @@ func (s *Store) Update(ctx context.Context, usr user.User) error {
 	return nil
 }
 
-// Delete removes a user from the database.
+// Delete soft deletes a user in the database by setting its date_deleted.
+// Like Update, it only succeeds when the version still matches.
 func (s *Store) Delete(ctx context.Context, usr user.User) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		"date_deleted" = :date_deleted,
+		"date_updated" = :date_updated,
+		"version" = version + 1
+	WHERE
+		user_id = :user_id AND
+		version = :version
+	RETURNING
+		version`
+
+	if err := s.execVersioned(ctx, q, usr); err != nil {
+		return fmt.Errorf("deleting userID[%s]: %w", usr.ID, err)
+	}
+
+	return nil
+}
+
+// Restore clears the date_deleted of a soft deleted user in the database.
+func (s *Store) Restore(ctx context.Context, usr user.User) error {
+	const q = `
+	UPDATE
+		users
+	SET
+		"date_deleted" = NULL,
+		"date_updated" = :date_updated,
+		"version" = version + 1
+	WHERE
+		user_id = :user_id AND
+		version = :version
+	RETURNING
+		version`
+
+	if err := s.execVersioned(ctx, q, usr); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return user.ErrUniqueEmail
+		}
+		return fmt.Errorf("restoring userID[%s]: %w", usr.ID, err)
+	}
+
+	return nil
+}
+
+// Purge permanently removes the users that were soft deleted before the
+// specified time. The foreign keys cascade the removal to products and sales.
+func (s *Store) Purge(ctx context.Context, deletedBefore time.Time) error {
 	data := struct {
-		UserID string `db:"user_id"`
+		DeletedBefore time.Time `db:"deleted_before"`
 	}{
-		UserID: usr.ID.String(),
+		DeletedBefore: deletedBefore.UTC(),
 	}
 
 	const q = `
 	DELETE FROM
 		users
 	WHERE
-		user_id = :user_id`
+		date_deleted IS NOT NULL AND
+		date_deleted < :deleted_before`
 
 	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
-		return fmt.Errorf("deleting userID[%s]: %w", usr.ID, err)
+		return fmt.Errorf("purging users: %w", err)
 	}
 
 	return nil
 }
 
 // Query retrieves a list of existing users from the database.
-func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]user.User, error) {
-	data := struct {
-		ID          string `db:"user_id"`
-		Name        string `db:"name"`
-		Email       string `db:"email"`
-		Offset      int    `db:"offset"`
-		RowsPerPage int    `db:"rows_per_page"`
-	}{
-		Offset:      (pageNumber - 1) * rowsPerPage,
-		RowsPerPage: rowsPerPage,
+func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	data := map[string]interface{}{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
 	}
 
-	const q = `
+	q := `
 	SELECT
-		*
+		` + selectColumns(filter.Fields, orderBy) + `
 	FROM
-		users
-	`
+		users`
+
 	buf := bytes.NewBufferString(q)
+	if err := applyFilter(filter, data, buf); err != nil {
+		return nil, err
+	}
+
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(orderByClause)
 	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
 
 	var usrs []dbUser
@@ -143,6 +236,120 @@ func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy orde
 	return toCoreUserSlice(usrs)
 }
 
+// QueryEach retrieves every user that matches the filter, in order, and hands
+// them to fn one at a time. The rows are read through a server-side cursor so
+// any number of users can be processed in bounded memory.
+func (s *Store) QueryEach(ctx context.Context, filter user.QueryFilter, orderBy []order.By, fn func(user.User) error) error {
+	data := map[string]interface{}{}
+
+	q := `
+	SELECT
+		` + selectColumns(filter.Fields, orderBy) + `
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+	if err := applyFilter(filter, data, buf); err != nil {
+		return err
+	}
+
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(orderByClause)
+
+	f := func(dbUsr dbUser) error {
+		usr, err := toCoreUser(dbUsr)
+		if err != nil {
+			return err
+		}
+		return fn(usr)
+	}
+
+	if err := database.NamedQueryCursor(ctx, s.log, s.db, buf.String(), data, fetchSize, f); err != nil {
+		return fmt.Errorf("selecting users: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByCursor retrieves a page of users that comes after, or before, the
+// position of the cursor. It is the keyset alternative to Query.
+func (s *Store) QueryByCursor(ctx context.Context, filter user.QueryFilter, orderBy []order.By, cursor keyset.Cursor, rowsPerPage int) (keyset.Page[user.User], error) {
+	columns, directions, err := orderColumns(orderBy)
+	if err != nil {
+		return keyset.Page[user.User]{}, err
+	}
+
+	orderKey := keysetOrder(columns, directions)
+
+	filterKey, err := keysetFilter(filter)
+	if err != nil {
+		return keyset.Page[user.User]{}, err
+	}
+
+	if !cursor.IsStart() && !cursor.Matches(orderKey, filterKey) {
+		return keyset.Page[user.User]{}, fmt.Errorf("cursor was created for another query: %w", keyset.ErrInvalidCursor)
+	}
+
+	travel := travelDirections(directions, cursor.IsPrev())
+
+	data := map[string]interface{}{
+		"rows_per_page": rowsPerPage + 1,
+	}
+
+	var extra []string
+	if !cursor.IsStart() {
+		clause, err := keysetClause(columns, travel, cursor.Key, data)
+		if err != nil {
+			return keyset.Page[user.User]{}, err
+		}
+		extra = append(extra, clause)
+	}
+
+	q := `
+	SELECT
+		` + selectColumns(filter.Fields, orderBy) + `
+	FROM
+		users`
+
+	buf := bytes.NewBufferString(q)
+	if err := applyFilter(filter, data, buf, extra...); err != nil {
+		return keyset.Page[user.User]{}, err
+	}
+	buf.WriteString(renderOrderBy(columns, travel))
+	buf.WriteString(" FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var dbUsrs []dbUser
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &dbUsrs); err != nil {
+		return keyset.Page[user.User]{}, fmt.Errorf("selecting users: %w", err)
+	}
+
+	dbUsrs, hasNext, hasPrev := cursorPage(dbUsrs, rowsPerPage, cursor)
+
+	usrs, err := toCoreUserSlice(dbUsrs)
+	if err != nil {
+		return keyset.Page[user.User]{}, err
+	}
+
+	page := keyset.Page[user.User]{
+		Items:   usrs,
+		HasNext: hasNext,
+		HasPrev: hasPrev,
+		Order:   orderKey,
+		Filter:  filterKey,
+	}
+
+	if len(dbUsrs) > 0 {
+		page.FirstKey = keyOf(dbUsrs[0], columns)
+		page.LastKey = keyOf(dbUsrs[len(dbUsrs)-1], columns)
+	}
+
+	return page, nil
+}
+
 // Count returns the total number of users in the DB.
 func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error) {
 	data := map[string]interface{}{}
@@ -154,7 +361,9 @@ func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error)
 		users`
 
 	buf := bytes.NewBufferString(q)
-	applyFilter(filter, data, buf)
+	if err := applyFilter(filter, data, buf); err != nil {
+		return 0, err
+	}
 
 	var count struct {
 		Count int `db:"count"`
@@ -180,7 +389,8 @@ func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, err
 	FROM
 		users
 	WHERE 
-		user_id = :user_id`
+		user_id = :user_id AND
+		date_deleted IS NULL`
 
 	var usr dbUser
 	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
@@ -208,15 +418,16 @@ func (s *Store) QueryByIDs(ctx context.Context, userIDs []uuid.UUID) ([]user.Use
 
 	const q = `
 	SELECT
-        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated
+        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated, date_deleted, version
 	FROM
 		users
 	WHERE
-		user_id = ANY(:user_id)`
+		user_id = ANY(:user_id) AND
+		date_deleted IS NULL`
 
 	var dbUsrs []dbUser
-	if err := sqldb.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbUsrs); err != nil {
-		if errors.Is(err, sqldb.ErrDBNotFound) {
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbUsrs); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
 			return nil, user.ErrNotFound
 		}
 		return nil, fmt.Errorf("namedquerystruct: %w", err)
@@ -239,7 +450,8 @@ func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (user.User
 	FROM
 		users
 	WHERE
-		email = :email`
+		email = :email AND
+		date_deleted IS NULL`
 
 	var usr dbUser
 	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &usr); err != nil {
@@ -251,3 +463,84 @@ func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (user.User
 
 	return toCoreUser(usr)
 }
+
+// QueryByEmails gets the users with the specified emails from the database.
+func (s *Store) QueryByEmails(ctx context.Context, emails []mail.Address) ([]user.User, error) {
+	addrs := make([]string, len(emails))
+	for i, email := range emails {
+		addrs[i] = email.Address
+	}
+
+	data := struct {
+		Email any `db:"email"`
+	}{
+		Email: dbarray.Array(addrs),
+	}
+
+	const q = `
+	SELECT
+        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated, date_deleted, version
+	FROM
+		users
+	WHERE
+		email = ANY(:email) AND
+		date_deleted IS NULL`
+
+	var dbUsrs []dbUser
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbUsrs); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil, user.ErrNotFound
+		}
+		return nil, fmt.Errorf("namedqueryslice: %w", err)
+	}
+
+	return toCoreUserSlice(dbUsrs)
+}
+
+// execVersioned executes a statement guarded by the version column. The
+// statement must return the new version. When no row comes back, the user was
+// changed by someone else and a VersionConflictError with the version the
+// user is at now is returned.
+func (s *Store) execVersioned(ctx context.Context, q string, usr user.User) error {
+	var dest struct {
+		Version int `db:"version"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, toDBUser(usr), &dest); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return s.versionConflict(ctx, usr.ID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// versionConflict looks up the version the user is at now. ErrVersionConflict
+// is returned as is when the user is gone.
+func (s *Store) versionConflict(ctx context.Context, userID uuid.UUID) error {
+	data := struct {
+		ID string `db:"user_id"`
+	}{
+		ID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		version
+	FROM
+		users
+	WHERE
+		user_id = :user_id`
+
+	var dest struct {
+		Version int `db:"version"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dest); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return user.ErrVersionConflict
+		}
+		return fmt.Errorf("querying version: %w", err)
+	}
+
+	return &user.VersionConflictError{Current: dest.Version}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -20,19 +21,46 @@ var (
 	ErrNotFound              = errors.New("user not found")
 	ErrUniqueEmail           = errors.New("email is not unique")
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrNotDeleted            = errors.New("user is not deleted")
+	ErrVersionConflict       = errors.New("user has been modified")
 )
 
+// VersionConflictError is returned when the user changed since it was read.
+// It matches ErrVersionConflict with errors.Is. Current is the version the
+// user is at now, the client has to fetch the user again to see what changed
+// before retrying.
+type VersionConflictError struct {
+	Current int
+}
+
+// Error implements the error interface.
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: current version %d", ErrVersionConflict, e.Current)
+}
+
+// Is reports whether the target is ErrVersionConflict.
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
 // Storer interface declares the behavior this package needs to priests and
 // retrieve data.
 type Storer interface {
+	WithinTran(ctx context.Context, fn func(s Storer) error) error
 	Create(ctx context.Context, usr User) error
+	CreateBatch(ctx context.Context, usrs []User) error
 	Update(ctx context.Context, usr User) error
 	Delete(ctx context.Context, usr User) error
-	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error)
+	Restore(ctx context.Context, usr User) error
+	Purge(ctx context.Context, deletedBefore time.Time) error
+	Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]User, error)
+	QueryByCursor(ctx context.Context, filter QueryFilter, orderBy []order.By, cursor keyset.Cursor, rowsPerPage int) (keyset.Page[User], error)
+	QueryEach(ctx context.Context, filter QueryFilter, orderBy []order.By, fn func(User) error) error
 	Count(ctx context.Context, filter QueryFilter) (int, error)
 	QueryByID(ctx context.Context, userID uuid.UUID) (User, error)
 	QueryByIDs(ctx context.Context, userID []uuid.UUID) ([]User, error)
 	QueryByEmail(ctx context.Context, email mail.Address) (User, error)
+	QueryByEmails(ctx context.Context, emails []mail.Address) ([]User, error)
 }
 
 // Core manages the set of APIs for user access.
@@ -49,13 +77,69 @@ func NewCore(storer Storer) *Core {
 
 // Create adds a new user to the system.
 func (c *Core) Create(ctx context.Context, nu NewUser) (User, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	usr, err := newUser(nu, time.Now())
 	if err != nil {
-		return User{}, fmt.Errorf("generatefrompassword: %w", err)
+		return User{}, err
+	}
+
+	if err := c.storer.Create(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("create: %w", err)
+	}
+
+	return usr, nil
+}
+
+// CreateBatch adds the users to the system within a single transaction,
+// inserting batchSize users per statement. Either all the users are added or
+// none of them are. Hashing the passwords is expensive, so it stops as soon as
+// the context is done.
+func (c *Core) CreateBatch(ctx context.Context, nus []NewUser, batchSize int) ([]User, error) {
+	if batchSize <= 0 {
+		batchSize = 100
 	}
 
 	now := time.Now()
 
+	usrs := make([]User, len(nus))
+	for i, nu := range nus {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("create batch: row[%d]: %w", i, err)
+		}
+
+		usr, err := newUser(nu, now)
+		if err != nil {
+			return nil, err
+		}
+		usrs[i] = usr
+	}
+
+	tran := func(s Storer) error {
+		for start := 0; start < len(usrs); start += batchSize {
+			end := min(start+batchSize, len(usrs))
+
+			if err := s.CreateBatch(ctx, usrs[start:end]); err != nil {
+				return fmt.Errorf("rows[%d:%d]: %w", start, end, err)
+			}
+		}
+
+		return nil
+	}
+
+	if err := c.storer.WithinTran(ctx, tran); err != nil {
+		return nil, fmt.Errorf("create batch: %w", err)
+	}
+
+	return usrs, nil
+}
+
+// newUser constructs a user that is ready to be stored from the new user
+// information.
+func newUser(nu NewUser, now time.Time) (User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("generatefrompassword: %w", err)
+	}
+
 	usr := User{
 		ID:           uuid.New(),
 		Name:         nu.Name,
@@ -66,17 +150,15 @@ func (c *Core) Create(ctx context.Context, nu NewUser) (User, error) {
 		Enabled:      true,
 		DateCreated:  now,
 		DateUpdated:  now,
-	}
-
-	if err := c.storer.Create(ctx, usr); err != nil {
-		return User{}, fmt.Errorf("create: %w", err)
+		Version:      1,
 	}
 
 	return usr, nil
 }
 
-// Update modifies information about a user.
-/* */
+// Update modifies information about a user. The update only succeeds if the
+// stored user still has the same version as the provided user, otherwise a
+// VersionConflictError is returned.
 func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error) {
 	if uu.Name != nil {
 		usr.Name = *uu.Name
@@ -112,11 +194,20 @@ func (c *Core) Update(ctx context.Context, usr User, uu UpdateUser) (User, error
 		return User{}, fmt.Errorf("update: %w", err)
 	}
 
+	usr.Version++
+
 	return usr, nil
 }
 
-// Delete removes the specified user.
+// Delete soft deletes the specified user. The user's products and sales are
+// kept so the sales history stays intact. Use Purge to remove the user for good.
+// Like Update, it returns ErrVersionConflict if the user changed in between.
 func (c *Core) Delete(ctx context.Context, usr User) error {
+	now := time.Now()
+
+	usr.DateDeleted = now
+	usr.DateUpdated = now
+
 	if err := c.storer.Delete(ctx, usr); err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
@@ -124,8 +215,39 @@ func (c *Core) Delete(ctx context.Context, usr User) error {
 	return nil
 }
 
+// Restore brings back a user that was soft deleted. ErrUniqueEmail is
+// returned when another user took the email in the meantime.
+func (c *Core) Restore(ctx context.Context, usr User) (User, error) {
+	if !usr.IsDeleted() {
+		return User{}, ErrNotDeleted
+	}
+
+	usr.DateDeleted = time.Time{}
+	usr.DateUpdated = time.Now()
+
+	if err := c.storer.Restore(ctx, usr); err != nil {
+		return User{}, fmt.Errorf("restore: %w", err)
+	}
+
+	usr.Version++
+
+	return usr, nil
+}
+
+// Purge permanently removes the users that were soft deleted longer ago than
+// the specified retention period. Their products and sales are removed too.
+func (c *Core) Purge(ctx context.Context, retention time.Duration) error {
+	deletedBefore := time.Now().Add(-retention)
+
+	if err := c.storer.Purge(ctx, deletedBefore); err != nil {
+		return fmt.Errorf("purge: deletedBefore[%s]: %w", deletedBefore.Format(time.RFC3339), err)
+	}
+
+	return nil
+}
+
 // Query retrieves a list of existing users.
-func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]User, error) {
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]User, error) {
 	if err := filter.Validate(); err != nil {
 		return nil, err
 	}
@@ -138,6 +260,35 @@ func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By,
 	return users, nil
 }
 
+// QueryEach calls fn for every existing user that matches the filter. It's
+// meant for exports, where the users can't be held in memory at once.
+func (c *Core) QueryEach(ctx context.Context, filter QueryFilter, orderBy []order.By, fn func(User) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.storer.QueryEach(ctx, filter, orderBy, fn); err != nil {
+		return fmt.Errorf("query each: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByCursor retrieves a page of existing users positioned by a cursor
+// instead of a page number.
+func (c *Core) QueryByCursor(ctx context.Context, filter QueryFilter, orderBy []order.By, cursor keyset.Cursor, rowsPerPage int) (keyset.Page[User], error) {
+	if err := filter.Validate(); err != nil {
+		return keyset.Page[User]{}, err
+	}
+
+	page, err := c.storer.QueryByCursor(ctx, filter, orderBy, cursor, rowsPerPage)
+	if err != nil {
+		return keyset.Page[User]{}, fmt.Errorf("querybycursor: %w", err)
+	}
+
+	return page, nil
+}
+
 // Count returns the total number of users.
 func (c *Core) Count(ctx context.Context, filter QueryFilter) (int, error) {
 	if err := filter.Validate(); err != nil {
@@ -7,6 +7,7 @@ import (
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user/stores/userdb"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbtest"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/docker"
 	"net/mail"
 	"runtime/debug"
@@ -22,7 +23,9 @@ func TestMain(m *testing.M) {
 	var err error
 	c, err = dbtest.StartDB()
 	if err != nil {
+		// The tests that don't need a database still run.
 		fmt.Println(err)
+		m.Run()
 		return
 	}
 	defer dbtest.StopDB(c)
@@ -31,6 +34,10 @@ func TestMain(m *testing.M) {
 }
 
 func Test_User(t *testing.T) {
+	if c == nil {
+		t.Skip("database is not available")
+	}
+
 	log, db, teardown := dbtest.NewUnit(t, c, "testuser")
 	defer func() {
 		if r := recover(); r != nil {
@@ -118,6 +125,18 @@ func Test_User(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould be able to update user.", dbtest.Success, testID)
 
+			_, err = core.Update(ctx, saved, upd)
+			if !errors.Is(err, user.ErrVersionConflict) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to update a stale user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to update a stale user.", dbtest.Success, testID)
+
+			var vc *user.VersionConflictError
+			if !errors.As(err, &vc) || vc.Current != saved.Version+1 {
+				t.Fatalf("\t%s\tTest %d:\tShould get back the current version of a stale user : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get back the current version of a stale user.", dbtest.Success, testID)
+
 			saved, err = core.QueryByEmail(ctx, *upd.Email)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user by Email : %s.", dbtest.Failed, testID, err)
@@ -155,11 +174,52 @@ func Test_User(t *testing.T) {
 				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to retrieve user : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould NOT be able to retrieve user.", dbtest.Success, testID)
+
+			var filter user.QueryFilter
+			filter.WithUserID(saved.ID)
+			filter.WithIncludeDeleted(true)
+
+			deleted, err := core.Query(ctx, filter, []order.By{user.DefaultOrderBy}, 1, 1)
+			if err != nil || len(deleted) != 1 || !deleted[0].IsDeleted() {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve deleted user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve deleted user.", dbtest.Success, testID)
+
+			// The email of a deleted user is free until the user is restored.
+			nu.Email = saved.Email
+			reuser, err := core.Create(ctx, nu)
+			if err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to reuse the email of a deleted user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to reuse the email of a deleted user.", dbtest.Success, testID)
+
+			if _, err := core.Restore(ctx, deleted[0]); !errors.Is(err, user.ErrUniqueEmail) {
+				t.Fatalf("\t%s\tTest %d:\tShould NOT be able to restore user while the email is used : %v.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould NOT be able to restore user while the email is used.", dbtest.Success, testID)
+
+			if err := core.Delete(ctx, reuser); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to delete user : %s.", dbtest.Failed, testID, err)
+			}
+
+			if _, err := core.Restore(ctx, deleted[0]); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to restore user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to restore user.", dbtest.Success, testID)
+
+			if _, err := core.QueryByID(ctx, saved.ID); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve restored user : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to retrieve restored user.", dbtest.Success, testID)
 		}
 	}
 }
 
 func Test_PagingUser(t *testing.T) {
+	if c == nil {
+		t.Skip("database is not available")
+	}
+
 	log, db, teardown := dbtest.NewUnit(t, c, "testpaging")
 	defer func() {
 		if r := recover(); r != nil {
@@ -179,7 +239,7 @@ func Test_PagingUser(t *testing.T) {
 			ctx := context.Background()
 
 			name := "User Gopher"
-			users1, err := core.Query(ctx, 1, 1)
+			users1, err := core.Query(ctx, user.QueryFilter{}, []order.By{user.DefaultOrderBy}, 1, 1)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user %q : %s.", dbtest.Failed, testID, name, err)
 			}
@@ -191,7 +251,7 @@ func Test_PagingUser(t *testing.T) {
 			t.Logf("\t%s\tTest %d:\tShould have a single user.", dbtest.Success, testID)
 
 			name = "Admin Gopher"
-			users2, err := core.Query(ctx, 1, 1)
+			users2, err := core.Query(ctx, user.QueryFilter{}, []order.By{user.DefaultOrderBy}, 1, 1)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve user %q : %s.", dbtest.Failed, testID, name, err)
 			}
@@ -202,7 +262,7 @@ func Test_PagingUser(t *testing.T) {
 			}
 			t.Logf("\t%s\tTest %d:\tShould have a single user.", dbtest.Success, testID)
 
-			users3, err := core.Query(ctx, 1, 2)
+			users3, err := core.Query(ctx, user.QueryFilter{}, []order.By{user.DefaultOrderBy}, 1, 2)
 			if err != nil {
 				t.Fatalf("\t%s\tTest %d:\tShould be able to retrieve 2 users for page 1 : %s.", dbtest.Failed, testID, err)
 			}
@@ -221,6 +281,36 @@ func Test_PagingUser(t *testing.T) {
 				t.Fatalf("\t%s\tTest %d:\tShould have different users : %s.", dbtest.Failed, testID, err)
 			}
 			t.Logf("\t%s\tTest %d:\tShould have different users.", dbtest.Success, testID)
+
+			var each []user.User
+			f := func(usr user.User) error {
+				each = append(each, usr)
+				return nil
+			}
+			if err := core.QueryEach(ctx, user.QueryFilter{}, []order.By{user.DefaultOrderBy}, f); err != nil {
+				t.Fatalf("\t%s\tTest %d:\tShould be able to iterate over all users : %s.", dbtest.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d:\tShould be able to iterate over all users.", dbtest.Success, testID)
+
+			if len(each) != len(users3) || each[0].ID != users3[0].ID {
+				t.Logf("\t\tTest %d:\tgot: %v", testID, len(each))
+				t.Logf("\t\tTest %d:\texp: %v", testID, len(users3))
+				t.Fatalf("\t%s\tTest %d:\tShould get the same users in the same order.", dbtest.Failed, testID)
+			}
+			t.Logf("\t%s\tTest %d:\tShould get the same users in the same order.", dbtest.Success, testID)
 		}
 	}
 }
+
+func Test_VersionConflictError(t *testing.T) {
+	err := fmt.Errorf("update: %w", &user.VersionConflictError{Current: 3})
+
+	if !errors.Is(err, user.ErrVersionConflict) {
+		t.Fatalf("Should match ErrVersionConflict : %s", err)
+	}
+
+	var vc *user.VersionConflictError
+	if !errors.As(err, &vc) || vc.Current != 3 {
+		t.Fatalf("Should carry the current version : %v", err)
+	}
+}
@@ -11,7 +11,7 @@ import (
 // Storer interface declares the behavior this package needs to persists and
 // retrieve data.
 type Storer interface {
-	Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]Summary, error)
+	Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]Summary, error)
 	Count(ctx context.Context, filter QueryFilter) (int, error)
 }
 
@@ -30,7 +30,7 @@ func NewCore(storer Storer) *Core {
 }
 
 // Query retrieves a list of existing users from the database.
-func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]Summary, error) {
+func (c *Core) Query(ctx context.Context, filter QueryFilter, orderBy []order.By, pageNumber int, rowsPerPage int) ([]Summary, error) {
 	users, err := c.storer.Query(ctx, filter, orderBy, pageNumber, rowsPerPage)
 	if err != nil {
 		return nil, fmt.Errorf("query: %w", err)
@@ -14,6 +14,9 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrPendingMigrations is returned when migrations are yet to be applied.
+var ErrPendingMigrations = errors.New("pending migrations")
+
 var (
 	//go:embed sql/migrate.sql
 	migrateDoc string
@@ -38,6 +41,44 @@ func Migrate(ctx context.Context, db *sqlx.DB) error {
 	return d.Migrate()
 }
 
+// Verify checks that the migrations defined in this package were all applied
+// to the database and weren't changed since. It doesn't change the database.
+func Verify(ctx context.Context, db *sqlx.DB) error {
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	driver, err := generic.New(db.DB, postgres.Dialect{})
+	if err != nil {
+		return fmt.Errorf("construct darwin driver: %w", err)
+	}
+
+	migrations := darwin.ParseMigrations(migrateDoc)
+
+	d := darwin.New(driver, migrations)
+	if err := d.Validate(); err != nil {
+		return fmt.Errorf("validate migrations: %w", err)
+	}
+
+	records, err := driver.All()
+	if err != nil {
+		return fmt.Errorf("query migrations: %w", err)
+	}
+
+	applied := make(map[float64]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			return fmt.Errorf("%w: version %v: %s", ErrPendingMigrations, migration.Version, migration.Description)
+		}
+	}
+
+	return nil
+}
+
 // Seed runs the seed document defined in this package against db. The queries
 // are run in a transaction and rolled back if any fail.
 func Seed(ctx context.Context, db *sqlx.DB) (err error) {
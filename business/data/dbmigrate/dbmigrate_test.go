@@ -0,0 +1,53 @@
+package dbmigrate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbmigrate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbtest"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/docker"
+	"testing"
+	"time"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		m.Run()
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_Verify(t *testing.T) {
+	if c == nil {
+		t.Skip("database is not available")
+	}
+
+	_, db, teardown := dbtest.NewUnit(t, c, "testverify")
+	t.Cleanup(teardown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := dbmigrate.Verify(ctx, db); err != nil {
+		t.Fatalf("Should verify a fully migrated database : %s", err)
+	}
+
+	// Forget the last migration, like a database a version behind.
+	const q = `DELETE FROM darwin_migrations WHERE version = (SELECT MAX(version) FROM darwin_migrations)`
+	if _, err := db.ExecContext(ctx, q); err != nil {
+		t.Fatalf("Should be able to forget the last migration : %s", err)
+	}
+
+	if err := dbmigrate.Verify(ctx, db); !errors.Is(err, dbmigrate.ErrPendingMigrations) {
+		t.Fatalf("Should report the pending migration : %v", err)
+	}
+}
@@ -42,4 +42,49 @@ FROM
         JOIN
     products AS p ON p.user_id = u.user_id
 GROUP BY
-    u.user_id
\ No newline at end of file
+    u.user_id;
+
+-- Version: 1.04
+-- Description: Add soft delete support to users
+ALTER TABLE users ADD COLUMN date_deleted TIMESTAMP NULL;
+
+-- Version: 1.05
+-- Description: Exclude soft deleted users from user_summary view.
+CREATE OR REPLACE VIEW user_summary AS
+SELECT
+    u.user_id   AS user_id,
+    u.name      AS user_name,
+    COUNT(p.*)  AS total_count,
+    SUM(p.cost) AS total_cost
+FROM
+    users AS u
+        JOIN
+    products AS p ON p.user_id = u.user_id
+WHERE
+    u.date_deleted IS NULL
+GROUP BY
+    u.user_id;
+
+-- Version: 1.06
+-- Description: Add version column to users for optimistic locking
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- Version: 1.07
+-- Description: Create table idempotency_keys
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT      NOT NULL,
+    subject         TEXT      NOT NULL,
+    request_hash    TEXT      NOT NULL,
+    status_code     INT       NOT NULL DEFAULT 0,
+    header          TEXT      NOT NULL DEFAULT '{}',
+    body            BYTEA     NULL,
+    date_created    TIMESTAMP NOT NULL,
+    date_expires    TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (idempotency_key, subject)
+);
+
+-- Version: 1.08
+-- Description: Keep emails unique among the users that aren't deleted
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE date_deleted IS NULL;
@@ -6,15 +6,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbschema"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbmigrate"
 	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/docker"
 	"testing"
 	"time"
 
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 // Success and failure markers.
@@ -50,7 +49,7 @@ func StopDB(c *docker.Container) {
 // NewUnit creates a test database inside a Docker container. It creates the
 // required table structure but the database is otherwise empty. It returns
 // the database to use as well as a function to call at the end of the test.
-func NewUnit(t *testing.T, c *docker.Container, dbName string) (*zap.SugaredLogger, *sqlx.DB, func()) {
+func NewUnit(t *testing.T, c *docker.Container, dbName string) (*logger.Logger, *sqlx.DB, func()) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -95,12 +94,12 @@ func NewUnit(t *testing.T, c *docker.Container, dbName string) (*zap.SugaredLogg
 
 	t.Log("Migrate and seed database ...")
 
-	if err := dbschema.Migrate(ctx, db); err != nil {
+	if err := dbmigrate.Migrate(ctx, db); err != nil {
 		t.Logf("Logs for %s\n%s:", c.ID, docker.DumpContainerLogs(c.ID))
 		t.Fatalf("Migrating error: %s", err)
 	}
 
-	if err := dbschema.Seed(ctx, db); err != nil {
+	if err := dbmigrate.Seed(ctx, db); err != nil {
 		t.Logf("Logs for %s\n%s:", c.ID, docker.DumpContainerLogs(c.ID))
 		t.Fatalf("Seeding error: %s", err)
 	}
@@ -111,12 +110,12 @@ func NewUnit(t *testing.T, c *docker.Container, dbName string) (*zap.SugaredLogg
 	With this, we're gonna run those tests and get the logs in a buffer and at the end of the test, we're gonna show the logs.
 	With this, we separate the logs from test output.*/
 	var buf bytes.Buffer
-	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
 	writer := bufio.NewWriter(&buf)
-	log := zap.New(
-		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel),
-		zap.WithCaller(true),
-	).Sugar()
+	log := logger.New(logger.Config{
+		Writer:  writer,
+		Level:   logger.LevelDebug,
+		Service: "TEST",
+	})
 
 	// teardown is the function that should be invoked when the caller is done
 	// with the database.
@@ -124,8 +123,6 @@ func NewUnit(t *testing.T, c *docker.Container, dbName string) (*zap.SugaredLogg
 		t.Helper()
 		db.Close()
 
-		log.Sync()
-
 		writer.Flush()
 		fmt.Println("******************** LOGS ********************")
 		fmt.Print(buf.String())
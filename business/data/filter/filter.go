@@ -0,0 +1,164 @@
+// Package filter provides support for filtering data with operators. Filters
+// are given in the query string in the form of "field[operator]=value", with
+// "field=value" being a short form for the default operator of the field.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Set of operators a condition can use.
+const (
+	EQ   = "eq"
+	NE   = "ne"
+	GT   = "gt"
+	GTE  = "gte"
+	LT   = "lt"
+	LTE  = "lte"
+	IN   = "in"
+	LIKE = "like"
+)
+
+var operators = map[string]bool{
+	EQ:   true,
+	NE:   true,
+	GT:   true,
+	GTE:  true,
+	LT:   true,
+	LTE:  true,
+	IN:   true,
+	LIKE: true,
+}
+
+// =============================================================================
+
+// Condition represents a comparison of a field against a value. For the IN
+// operator the value is a slice of strings.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    any
+}
+
+// NewCondition constructs a new Condition value with no checks.
+func NewCondition(field string, operator string, value any) Condition {
+	return Condition{
+		Field:    field,
+		Operator: operator,
+		Value:    value,
+	}
+}
+
+// Allowed represents the operators each field supports.
+type Allowed map[string][]string
+
+// Check validates the conditions against the set of allowed fields and
+// operators.
+func (a Allowed) Check(conditions []Condition) error {
+	for _, c := range conditions {
+		ops, exists := a[c.Field]
+		if !exists {
+			return validate.NewFieldsError(c.Field, errors.New("filter field does not exist"))
+		}
+
+		var found bool
+		for _, op := range ops {
+			if op == c.Operator {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return validate.NewFieldsError(c.Field, fmt.Errorf("operator %q is not supported", c.Operator))
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Param represents a filter as it was found in the query string.
+type Param struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// Values returns the value of the param split into a list for the IN operator.
+func (p Param) Values() []string {
+	values := strings.Split(p.Value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+
+	return values
+}
+
+var paramKey = regexp.MustCompile(`^([A-Za-z_]+)\[([a-z]+)\]$`)
+
+// Parse extracts the filter params for the specified fields from the query
+// string. The defaults map each field to the operator used when none is
+// given. Other query string keys, like paging, are ignored.
+func Parse(r *http.Request, defaults map[string]string) ([]Param, error) {
+	query := r.URL.Query()
+
+	// Sort the keys so the conditions, and the queries built from them, are
+	// always in the same order.
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []Param
+
+	for _, key := range keys {
+		values := query[key]
+		field, operator := key, ""
+
+		if m := paramKey.FindStringSubmatch(key); m != nil {
+			field, operator = m[1], m[2]
+
+			if _, exists := defaults[field]; !exists {
+				return nil, validate.NewFieldsError(key, errors.New("filter field does not exist"))
+			}
+
+			if !operators[operator] {
+				return nil, validate.NewFieldsError(key, fmt.Errorf("unknown operator: %s", operator))
+			}
+		}
+
+		// A key with brackets that isn't in the form of "field[operator]",
+		// like "roles[IN]", is a mistake of the client, not another param.
+		if operator == "" && strings.ContainsAny(key, "[]") {
+			return nil, validate.NewFieldsError(key, errors.New("malformed filter, expected field[operator]"))
+		}
+
+		defaultOp, exists := defaults[field]
+		if !exists {
+			continue
+		}
+
+		if operator == "" {
+			operator = defaultOp
+		}
+
+		for _, value := range values {
+			params = append(params, Param{
+				Field:    field,
+				Operator: operator,
+				Value:    value,
+			})
+		}
+	}
+
+	return params, nil
+}
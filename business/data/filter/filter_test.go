@@ -0,0 +1,86 @@
+package filter_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/filter"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var defaults = map[string]string{
+	"name":         filter.LIKE,
+	"roles":        filter.EQ,
+	"date_created": filter.EQ,
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		exp   []filter.Param
+	}{
+		{"default operator", "name=bill", []filter.Param{{Field: "name", Operator: filter.LIKE, Value: "bill"}}},
+		{"explicit operator", "roles[in]=ADMIN,USER", []filter.Param{{Field: "roles", Operator: filter.IN, Value: "ADMIN,USER"}}},
+		{"every value", "name[eq]=bill&name[eq]=ed", []filter.Param{
+			{Field: "name", Operator: filter.EQ, Value: "bill"},
+			{Field: "name", Operator: filter.EQ, Value: "ed"},
+		}},
+		{"sorted keys", "roles=ADMIN&date_created[gte]=2023-01-01T00:00:00Z", []filter.Param{
+			{Field: "date_created", Operator: filter.GTE, Value: "2023-01-01T00:00:00Z"},
+			{Field: "roles", Operator: filter.EQ, Value: "ADMIN"},
+		}},
+		{"other keys", "page=2&rows=10&orderBy=name", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+
+			params, err := filter.Parse(r, defaults)
+			if err != nil {
+				t.Fatalf("Should be able to parse the filter : %s", err)
+			}
+			if !reflect.DeepEqual(params, tt.exp) {
+				t.Fatalf("Should get back the params : exp %+v got %+v", tt.exp, params)
+			}
+		})
+	}
+}
+
+func Test_ParseRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"unknown field", "age[gt]=10", "age[gt]"},
+		{"unknown operator", "name[foo]=bill", "name[foo]"},
+		{"uppercase operator", "roles[IN]=ADMIN", "roles[IN]"},
+		{"empty operator", "name[]=bill", "name[]"},
+		{"unclosed bracket", "name[eq=bill", "name[eq"},
+		{"nested brackets", "name[eq][in]=bill", "name[eq][in]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+
+			_, err := filter.Parse(r, defaults)
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 || fe[0].Field != tt.field {
+				t.Fatalf("Should reject the filter with a field error on %s : %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func Test_Values(t *testing.T) {
+	p := filter.Param{Field: "roles", Operator: filter.IN, Value: "ADMIN, USER"}
+
+	if got, exp := p.Values(), []string{"ADMIN", "USER"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Should split the values : exp %v got %v", exp, got)
+	}
+}
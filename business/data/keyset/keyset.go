@@ -0,0 +1,91 @@
+// Package keyset provides support for keyset (cursor) paging. Instead of
+// skipping rows with an OFFSET, a query continues from the order key of the
+// last row that was seen, which stays fast and stable on large tables that
+// change between requests.
+package keyset
+
+import "errors"
+
+// ErrInvalidCursor is returned when a cursor can't be decoded, its signature
+// doesn't match or it doesn't fit the query it is used with, for example when
+// it was created for a different ordering.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Set of directions a cursor can move in.
+const (
+	Next = "next"
+	Prev = "prev"
+)
+
+// =============================================================================
+
+// Cursor marks a position in an ordered result set. Key holds the order key of
+// the row at that position, ending with the primary key as a tie-breaker. A
+// cursor without a key points at the start of the result set. Order and
+// Filter describe the query the position belongs to, the cursor can't be used
+// with another one.
+type Cursor struct {
+	Direction string   `json:"d"`
+	Key       []string `json:"k"`
+	Order     []string `json:"o,omitempty"`
+	Filter    string   `json:"f,omitempty"`
+}
+
+// IsStart reports whether the cursor points at the start of the result set.
+func (c Cursor) IsStart() bool {
+	return len(c.Key) == 0
+}
+
+// IsPrev reports whether the cursor moves backwards through the result set.
+func (c Cursor) IsPrev() bool {
+	return c.Direction == Prev
+}
+
+// Matches reports whether the cursor was created for the query with the
+// specified order and filter.
+func (c Cursor) Matches(order []string, filter string) bool {
+	if c.Filter != filter || len(c.Order) != len(order) {
+		return false
+	}
+
+	for i := range order {
+		if c.Order[i] != order[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// =============================================================================
+
+// Page represents a set of rows found by a keyset query along with the order
+// keys of its first and last row. Order and Filter describe the query, they
+// are handed to the cursors of the page.
+type Page[T any] struct {
+	Items    []T
+	FirstKey []string
+	LastKey  []string
+	HasNext  bool
+	HasPrev  bool
+	Order    []string
+	Filter   string
+}
+
+// NextCursor returns the cursor for the page after this one, if there is one.
+func (p Page[T]) NextCursor() (Cursor, bool) {
+	if !p.HasNext || len(p.LastKey) == 0 {
+		return Cursor{}, false
+	}
+
+	return Cursor{Direction: Next, Key: p.LastKey, Order: p.Order, Filter: p.Filter}, true
+}
+
+// PrevCursor returns the cursor for the page before this one, if there is one.
+func (p Page[T]) PrevCursor() (Cursor, bool) {
+	if !p.HasPrev || len(p.FirstKey) == 0 {
+		return Cursor{}, false
+	}
+
+	return Cursor{Direction: Prev, Key: p.FirstKey, Order: p.Order, Filter: p.Filter}, true
+}
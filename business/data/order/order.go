@@ -38,30 +38,48 @@ func NewBy(field string, direction string) By {
 
 // =============================================================================
 
-// Parse constructs a order.By value by parsing a string in the form
-// of "field,direction".
-func Parse(r *http.Request, defaultOrder By) (By, error) {
+// Parse constructs a list of order.By values by parsing a string in the form
+// of "field,direction;field,direction". The first field is the most
+// significant one. The direction is optional and defaults to ASC. Note that
+// clients have to escape the semicolon as %3B in the query string.
+func Parse(r *http.Request, defaultOrder By) ([]By, error) {
 	v := r.URL.Query().Get("orderBy")
 
 	if v == "" {
-		return defaultOrder, nil
+		return []By{defaultOrder}, nil
 	}
 
-	orderParts := strings.Split(v, ",")
+	var list []By
+	seen := make(map[string]bool)
 
-	var by By
-	switch len(orderParts) {
-	case 1:
-		by = NewBy(strings.Trim(orderParts[0], " "), ASC)
-	case 2:
-		by = NewBy(strings.Trim(orderParts[0], " "), strings.Trim(orderParts[1], " "))
-	default:
-		return By{}, validate.NewFieldsError(v, errors.New("unknown order field"))
-	}
+	for _, part := range strings.Split(v, ";") {
+		orderParts := strings.Split(part, ",")
+
+		var by By
+		switch len(orderParts) {
+		case 1:
+			by = NewBy(strings.Trim(orderParts[0], " "), ASC)
+		case 2:
+			by = NewBy(strings.Trim(orderParts[0], " "), strings.Trim(orderParts[1], " "))
+		default:
+			return nil, validate.NewFieldsError(v, errors.New("unknown order field"))
+		}
+
+		if by.Field == "" {
+			return nil, validate.NewFieldsError(v, errors.New("missing order field"))
+		}
+
+		if _, exists := directions[by.Direction]; !exists {
+			return nil, validate.NewFieldsError(v, fmt.Errorf("unknown direction: %s", by.Direction))
+		}
+
+		if seen[by.Field] {
+			return nil, validate.NewFieldsError(v, fmt.Errorf("field ordered more than once: %s", by.Field))
+		}
+		seen[by.Field] = true
 
-	if _, exists := directions[by.Direction]; !exists {
-		return By{}, validate.NewFieldsError(v, fmt.Errorf("unknown direction: %s", by.Direction))
+		list = append(list, by)
 	}
 
-	return by, nil
+	return list, nil
 }
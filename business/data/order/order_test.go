@@ -0,0 +1,79 @@
+package order_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/order"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var defaultOrder = order.NewBy("user_id", order.ASC)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderBy string
+		exp     []order.By
+	}{
+		{"default", "", []order.By{defaultOrder}},
+		{"field", "name", []order.By{order.NewBy("name", order.ASC)}},
+		{"direction", "name,DESC", []order.By{order.NewBy("name", order.DESC)}},
+		{"spaces", " name , DESC ", []order.By{order.NewBy("name", order.DESC)}},
+		{"several fields", "department,ASC;name,DESC", []order.By{
+			order.NewBy("department", order.ASC),
+			order.NewBy("name", order.DESC),
+		}},
+		{"mixed directions", "enabled;department,DESC;name", []order.By{
+			order.NewBy("enabled", order.ASC),
+			order.NewBy("department", order.DESC),
+			order.NewBy("name", order.ASC),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			by, err := order.Parse(newRequest(tt.orderBy), defaultOrder)
+			if err != nil {
+				t.Fatalf("Should be able to parse the order : %s", err)
+			}
+			if !reflect.DeepEqual(by, tt.exp) {
+				t.Fatalf("Should get back the order : exp %+v got %+v", tt.exp, by)
+			}
+		})
+	}
+}
+
+func Test_ParseRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		orderBy string
+	}{
+		{"unknown direction", "name,UP"},
+		{"lowercase direction", "name,desc"},
+		{"unknown direction in a list", "department,ASC;name,SIDEWAYS"},
+		{"too many parts", "name,ASC,DESC"},
+		{"missing field", ",DESC"},
+		{"empty entry", "name;;email"},
+		{"trailing separator", "name;"},
+		{"duplicate field", "name,ASC;name,DESC"},
+		{"duplicate field apart", "name;email;name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := order.Parse(newRequest(tt.orderBy), defaultOrder)
+
+			if fe := validate.GetFieldErrors(err); len(fe) != 1 {
+				t.Fatalf("Should reject the order with a field error : %v", err)
+			}
+		})
+	}
+}
+
+// newRequest constructs a request with the orderBy query parameter.
+func newRequest(orderBy string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/users?"+url.Values{"orderBy": {orderBy}}.Encode(), nil)
+}
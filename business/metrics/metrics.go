@@ -3,8 +3,14 @@ package metrics
 
 import (
 	"context"
+	"database/sql"
 	"expvar"
+	"net/http"
 	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // This holds the single instance(singleton) of the metrics value needed for
@@ -22,6 +28,23 @@ type metrics struct {
 	requests   *expvar.Int
 	errors     *expvar.Int
 	panics     *expvar.Int
+
+	// ratelimited counts the requests rejected by rate limiting.
+	ratelimited *expvar.Int
+
+	// db holds the statistics of the database connection pool.
+	db *expvar.Map
+
+	// The route metrics are labeled by route pattern, method and status
+	// class. They are only exposed in the Prometheus format.
+	duration  *prometheus.HistogramVec
+	size      *prometheus.SummaryVec
+	responses *prometheus.CounterVec
+
+	// The query metrics are labeled by the name of the function making the
+	// query.
+	queryDuration *prometheus.HistogramVec
+	queries       *prometheus.CounterVec
 }
 
 // init constructs the metrics value that will be used to capture metrics.
@@ -34,7 +57,45 @@ func init() {
 		requests:   expvar.NewInt("requests"),
 		errors:     expvar.NewInt("errors"),
 		panics:     expvar.NewInt("panics"),
+
+		ratelimited: expvar.NewInt("ratelimited"),
+		db:          expvar.NewMap("db"),
+
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle requests, by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, routeLabels),
+
+		size: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Name:       "http_response_size_bytes",
+			Help:       "Size of the response bodies sent, by route.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, routeLabels),
+
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_responses_total",
+			Help:      "Number of responses sent, by route.",
+		}, routeLabels),
+
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database calls, by query name.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"query"}),
+
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_queries_total",
+			Help:      "Number of database calls, by query name and outcome.",
+		}, []string{"query", "status"}),
 	}
+
+	registry = newRegistry(m)
 }
 
 // =============================================================================
@@ -103,3 +164,72 @@ func AddPanics(ctx context.Context) int64 {
 
 	return 0
 }
+
+// AddRateLimited increments the rate limited requests metric by 1.
+func AddRateLimited(ctx context.Context) int64 {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.ratelimited.Add(1)
+		return v.ratelimited.Value()
+	}
+
+	return 0
+}
+
+// routeLabels are the labels of the route metrics. The route is the pattern
+// the route was registered with, so ids in the path don't create new series.
+var routeLabels = []string{"route", "method", "status"}
+
+// AddRoute records the duration, response size and status of a request to the
+// route.
+func AddRoute(ctx context.Context, route string, method string, status int, size int64, d time.Duration) {
+	v, ok := ctx.Value(key).(*metrics)
+	if !ok {
+		return
+	}
+
+	// No status means the request failed before a response was written,
+	// like with an error that got past Errors.
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	labels := prometheus.Labels{
+		"route":  route,
+		"method": method,
+		"status": strconv.Itoa(status/100) + "xx",
+	}
+
+	v.duration.With(labels).Observe(d.Seconds())
+	v.size.With(labels).Observe(float64(size))
+	v.responses.With(labels).Inc()
+}
+
+// AddQuery records the duration and outcome of a database call. Unlike the
+// request metrics it doesn't need the context, so calls made outside of a
+// request are recorded too.
+func AddQuery(name string, failed bool, d time.Duration) {
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+
+	m.queryDuration.WithLabelValues(name).Observe(d.Seconds())
+	m.queries.WithLabelValues(name, status).Inc()
+}
+
+// SetDBStats sets the statistics of the database connection pool. Prometheus
+// reads them when scraped, this keeps /debug/vars in step.
+func SetDBStats(stats sql.DBStats) {
+	set := func(key string, value int64) {
+		v := new(expvar.Int)
+		v.Set(value)
+		m.db.Set(key, v)
+	}
+
+	set("max_open", int64(stats.MaxOpenConnections))
+	set("open", int64(stats.OpenConnections))
+	set("in_use", int64(stats.InUse))
+	set("idle", int64(stats.Idle))
+	set("wait_count", stats.WaitCount)
+	set("wait_duration_ms", stats.WaitDuration.Milliseconds())
+}
@@ -0,0 +1,105 @@
+package metrics_test
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ScrapeCounters(t *testing.T) {
+	ctx := metrics.Set(context.Background())
+
+	metrics.AddRequests(ctx)
+	metrics.AddErrors(ctx)
+	metrics.AddPanics(ctx)
+	metrics.AddRateLimited(ctx)
+
+	body := scrape(t)
+
+	counters := map[string]string{
+		"requests":    "sales_requests_total",
+		"errors":      "sales_errors_total",
+		"panics":      "sales_panics_total",
+		"ratelimited": "sales_ratelimited_total",
+	}
+	for name, metric := range counters {
+		line := fmt.Sprintf("%s %s\n", metric, expvar.Get(name).String())
+		if !strings.Contains(body, line) {
+			t.Fatalf("Should expose the expvar counter %s as %q", name, line)
+		}
+	}
+}
+
+func Test_ScrapeRoutes(t *testing.T) {
+	ctx := metrics.Set(context.Background())
+
+	metrics.AddRoute(ctx, "/v1/users/:user_id", http.MethodGet, http.StatusOK, 10, time.Millisecond)
+	metrics.AddRoute(ctx, "/v1/users/:user_id", http.MethodGet, http.StatusNotFound, 10, time.Millisecond)
+	metrics.AddRoute(ctx, "/v1/users", http.MethodPost, 0, 0, time.Millisecond)
+
+	body := scrape(t)
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"ok", `sales_http_responses_total{method="GET",route="/v1/users/:user_id",status="2xx"} 1`},
+		{"client error", `sales_http_responses_total{method="GET",route="/v1/users/:user_id",status="4xx"} 1`},
+		{"no status", `sales_http_responses_total{method="POST",route="/v1/users",status="5xx"} 1`},
+		{"duration", `sales_http_request_duration_seconds_count{method="GET",route="/v1/users/:user_id",status="2xx"} 1`},
+		{"size", `sales_http_response_size_bytes_sum{method="GET",route="/v1/users/:user_id",status="4xx"} 10`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.line+"\n") {
+				t.Fatalf("Should expose %q : %s", tt.line, body)
+			}
+		})
+	}
+
+	if strings.Contains(body, `route="/v1/users",status="2xx"`) {
+		t.Fatalf("Should NOT record a request without a status as a success.")
+	}
+}
+
+func Test_AddQuery(t *testing.T) {
+	metrics.AddQuery("userdb.QueryByID", false, time.Millisecond)
+	metrics.AddQuery("userdb.QueryByID", true, time.Millisecond)
+
+	body := scrape(t)
+
+	for _, line := range []string{
+		`sales_db_queries_total{query="userdb.QueryByID",status="ok"} 1`,
+		`sales_db_queries_total{query="userdb.QueryByID",status="error"} 1`,
+		`sales_db_query_duration_seconds_count{query="userdb.QueryByID"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Should expose %q", line)
+		}
+	}
+}
+
+// scrape returns the metrics as a Prometheus server reads them.
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	metrics.Handler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Should be able to scrape the metrics : got %d", w.Code)
+	}
+
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatalf("Should be able to read the metrics : %s", err)
+	}
+
+	return string(body)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"expvar"
+	"github.com/jmoiron/sqlx"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the names of the metrics of the service.
+const namespace = "sales"
+
+// registry holds the metrics exposed in the Prometheus format. The expvar
+// values stay the source of truth, the registry reads them when scraped.
+var registry *prometheus.Registry
+
+// newRegistry registers the metrics with a new registry.
+func newRegistry(m *metrics) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+
+		expvarCounter(m.requests, "requests_total", "Number of requests handled."),
+		expvarCounter(m.errors, "errors_total", "Number of requests that failed with an error."),
+		expvarCounter(m.panics, "panics_total", "Number of panics recovered while handling requests."),
+		expvarCounter(m.ratelimited, "ratelimited_total", "Number of requests rejected by rate limiting."),
+
+		m.duration,
+		m.size,
+		m.responses,
+		m.queryDuration,
+		m.queries,
+	)
+
+	return registry
+}
+
+// expvarCounter exposes an expvar value as a Prometheus counter.
+func expvarCounter(v *expvar.Int, name string, help string) prometheus.Collector {
+	opts := prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}
+
+	return prometheus.NewCounterFunc(opts, func() float64 {
+		return float64(v.Value())
+	})
+}
+
+// Handler returns the handler exposing the metrics in the Prometheus text
+// format, along with the connection pool statistics of the database.
+func Handler(db *sqlx.DB) http.Handler {
+	gatherers := prometheus.Gatherers{registry}
+
+	if db != nil {
+		dbRegistry := prometheus.NewRegistry()
+		dbRegistry.MustRegister(collectors.NewDBStatsCollector(db.DB, "postgres"))
+		gatherers = append(gatherers, dbRegistry)
+	}
+
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
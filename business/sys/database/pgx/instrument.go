@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// slowQueryThreshold holds the duration, in nanoseconds, after which a call
+// is logged as slow.
+var slowQueryThreshold atomic.Int64
+
+// SetSlowQueryThreshold sets the duration after which a call to the database
+// is logged as a slow query. Zero turns the slow query log off.
+func SetSlowQueryThreshold(d time.Duration) {
+	slowQueryThreshold.Store(int64(d))
+}
+
+// call measures a single call to the database made through the helpers.
+type call struct {
+	op       string
+	name     string
+	query    string
+	data     any
+	start    time.Time
+	excluded time.Duration
+	span     *trace.Span
+}
+
+// startCall starts measuring a call, in a span that is a child of the span
+// held by the context. The call is named after the function that called the
+// helper, like userdb.(*Store).QueryByID.
+func startCall(ctx context.Context, op string, query string, data any) (context.Context, *call) {
+	ctx, span := trace.Start(ctx, op, trace.Attr("db.system", "postgresql"))
+	if query != "" {
+		span.SetAttributes(trace.Attr("db.statement", query))
+	}
+	span.SetKind(trace.SpanKindClient)
+
+	c := call{
+		op:    op,
+		name:  callerName(),
+		query: query,
+		data:  data,
+		start: time.Now(),
+		span:  span,
+	}
+
+	return ctx, &c
+}
+
+// exclude leaves time spent outside of the database, like handing rows to the
+// caller, out of the duration of the call.
+func (c *call) exclude(d time.Duration) {
+	c.excluded += d
+}
+
+// end records the duration and outcome of the call and logs it, at the warn
+// level when it's slow and at the debug level otherwise. Not finding a row is
+// an answer, not a failure.
+func (c *call) end(ctx context.Context, log *logger.Logger, err error) {
+	d := time.Since(c.start) - c.excluded
+
+	failed := err != nil && !errors.Is(err, ErrDBNotFound)
+	if failed {
+		c.span.SetError(err)
+	}
+	c.span.End()
+
+	metrics.AddQuery(c.name, failed, d)
+
+	kv := []any{"op", c.op, "name", c.name, "duration", d.String()}
+	if c.query != "" {
+		kv = append(kv, "query", queryString(c.query, c.data))
+	}
+
+	if threshold := time.Duration(slowQueryThreshold.Load()); threshold > 0 && d >= threshold {
+		log.Warn(ctx, "database slow query", append(kv, "threshold", threshold.String())...)
+		return
+	}
+
+	if err != nil {
+		kv = append(kv, "ERROR", err)
+	}
+	log.Debug(ctx, "database query", kv...)
+}
+
+// pkgPrefix is the prefix of the names of the functions of this package.
+var pkgPrefix = reflect.TypeOf(call{}).PkgPath() + "."
+
+// callerName returns the name of the first function outside of this package
+// in the call stack.
+func callerName() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) {
+			return frame.Function[strings.LastIndex(frame.Function, "/")+1:]
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
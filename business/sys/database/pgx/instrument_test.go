@@ -0,0 +1,105 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// execDB accepts every statement without running it.
+type execDB struct {
+	sqlx.ExtContext
+	queries []string
+}
+
+func (db *execDB) DriverName() string {
+	return "postgres"
+}
+
+func (db *execDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	db.queries = append(db.queries, query)
+	return driver.RowsAffected(1), nil
+}
+
+func Test_NamedExecContextLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(logger.Config{Writer: &buf, Level: logger.LevelDebug})
+
+	data := struct {
+		Email   string `db:"email"`
+		Version int    `db:"version"`
+	}{
+		Email:   "bill@ardanlabs.com",
+		Version: 3,
+	}
+
+	const q = `
+	UPDATE
+		users
+	SET
+		email = :email
+	WHERE
+		version = :version`
+
+	db := execDB{}
+	if err := database.NamedExecContext(context.Background(), log, &db, q, data); err != nil {
+		t.Fatalf("Should be able to execute the statement : %s", err)
+	}
+	if len(db.queries) != 1 {
+		t.Fatalf("Should execute the statement once : got %d", len(db.queries))
+	}
+
+	if strings.Contains(buf.String(), "bill@ardanlabs.com") {
+		t.Fatalf("Should never log the text values of a query : %s", buf.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Should log the call once : %s", buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Should log json : %s", err)
+	}
+
+	if entry["query"] != "UPDATE users SET email = '***' WHERE version = 3" {
+		t.Fatalf("Should log the query with the text values masked : got %q", entry["query"])
+	}
+	if entry["name"] != "pgx_test.Test_NamedExecContextLog" {
+		t.Fatalf("Should name the call after the function calling the helper : got %q", entry["name"])
+	}
+	if entry["op"] != "database.NamedExecContext" {
+		t.Fatalf("Should log the helper used : got %q", entry["op"])
+	}
+}
+
+func Test_ExecContextCaller(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(logger.Config{Writer: &buf, Level: logger.LevelDebug})
+
+	// ExecContext calls NamedExecContext, the name skips both.
+	if err := database.ExecContext(context.Background(), log, &execDB{}, "DELETE FROM users"); err != nil {
+		t.Fatalf("Should be able to execute the statement : %s", err)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Should log json : %s", err)
+	}
+
+	if entry["name"] != "pgx_test.Test_ExecContextCaller" {
+		t.Fatalf("Should name the call after the function outside the package : got %q", entry["name"])
+	}
+	if entry["query"] != "DELETE FROM users" {
+		t.Fatalf("Should log the query : got %q", entry["query"])
+	}
+}
@@ -6,15 +6,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 )
 
 /* Specific to postgres related to certain errors that could come back out of the driver. */
@@ -107,14 +107,22 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
 
+// Checker returns a health check of the database, for the readiness checks.
+func Checker(db *sqlx.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return StatusCheck(ctx, db)
+	}
+}
+
 // WithinTran runs passed function and do commit/rollback at the end.
-func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(*sqlx.Tx) error) error {
-	traceID := web.GetTraceID(ctx)
+func WithinTran(ctx context.Context, log *logger.Logger, db *sqlx.DB, fn func(*sqlx.Tx) error) (err error) {
+	ctx, c := startCall(ctx, "database.WithinTran", "", nil)
+	defer func() { c.end(ctx, log, err) }()
 
-	log.Infow("begin tran")
-	tx, err := db.Beginx()
+	log.Info(ctx, "begin tran")
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin tran: %w", err)
+		return fmt.Errorf("begin tran: %w", pgError(ctx, err))
 	}
 
 	// We can defer the rollback since the code checks if the transaction
@@ -124,9 +132,9 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn fun
 			if errors.Is(err, sql.ErrTxDone) {
 				return
 			}
-			log.Errorw("unable to rollback tran", "trace_id", traceID, "ERROR", err)
+			log.Error(ctx, "unable to rollback tran", "ERROR", err)
 		}
-		log.Infow("rollback tran", "trace_id", traceID)
+		log.Info(ctx, "rollback tran")
 	}()
 
 	if err := fn(tx); err != nil {
@@ -139,38 +147,25 @@ func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn fun
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("commit tran: %w", err)
 	}
-	log.Infow("commit tran", "trace_id", traceID)
+	log.Info(ctx, "commit tran")
 
 	return nil
 }
 
 // ExecContext is a helper function to execute a CUD operation with
 // logging and tracing.
-func ExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string) error {
+func ExecContext(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string) error {
 	return NamedExecContext(ctx, log, db, query, struct{}{})
 }
 
 // NamedExecContext is a helper function to execute a CUD operation with
 // logging and tracing where field replacement is necessary.
-func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any) error {
-	q := queryString(query, data)
-
-	if _, ok := data.(struct{}); ok {
-		log.WithOptions(zap.AddCallerSkip(3)).Infow("database.NamedExecContext", "trace_id", web.GetTraceID(ctx), "query", q)
-	} else {
-		log.WithOptions(zap.AddCallerSkip(2)).Infow("database.NamedExecContext", "trace_id", web.GetTraceID(ctx), "query", q)
-	}
+func NamedExecContext(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any) (err error) {
+	ctx, c := startCall(ctx, "database.NamedExecContext", query, data)
+	defer func() { c.end(ctx, log, err) }()
 
 	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
-		if pqerr, ok := err.(*pgconn.PgError); ok {
-			switch pqerr.Code {
-			case undefinedTable:
-				return ErrUndefinedTable
-			case uniqueViolation:
-				return ErrDBDuplicatedEntry
-			}
-		}
-		return err
+		return pgError(ctx, err)
 	}
 
 	return nil
@@ -178,31 +173,29 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 
 // QuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice.
-func QuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, dest *[]T) error {
+func QuerySlice[T any](ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, dest *[]T) error {
 	return namedQuerySlice(ctx, log, db, query, struct{}{}, dest, false)
 }
 
 // NamedQuerySlice is a helper function for executing queries that return a
 // collection of data to be unmarshalled into a slice where field replacement is
 // necessary.
-func NamedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
+func NamedQuerySlice[T any](ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
 	return namedQuerySlice(ctx, log, db, query, data, dest, false)
 }
 
 // NamedQuerySliceUsingIn is a helper function for executing queries that return
 // a collection of data to be unmarshalled into a slice where field replacement
 // is necessary. Use this if the query has an IN clause.
-func NamedQuerySliceUsingIn[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
+func NamedQuerySliceUsingIn[T any](ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest *[]T) error {
 	return namedQuerySlice(ctx, log, db, query, data, dest, true)
 }
 
-func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest *[]T, withIn bool) error {
-	q := queryString(query, data)
-
-	log.WithOptions(zap.AddCallerSkip(3)).Infow("database.NamedQuerySlice", "trace_id", web.GetTraceID(ctx), "query", q)
+func namedQuerySlice[T any](ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest *[]T, withIn bool) (err error) {
+	ctx, c := startCall(ctx, "database.NamedQuerySlice", query, data)
+	defer func() { c.end(ctx, log, err) }()
 
 	var rows *sqlx.Rows
-	var err error
 
 	switch withIn {
 	case true:
@@ -226,10 +219,7 @@ func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 	}
 
 	if err != nil {
-		if pqerr, ok := err.(*pgconn.PgError); ok && pqerr.Code == undefinedTable {
-			return ErrUndefinedTable
-		}
-		return err
+		return pgError(ctx, err)
 	}
 	defer rows.Close()
 
@@ -241,37 +231,151 @@ func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 		}
 		slice = append(slice, *v)
 	}
+
+	// A query canceled while the rows are read surfaces here.
+	if err := rows.Err(); err != nil {
+		return pgError(ctx, err)
+	}
 	*dest = slice
 
 	return nil
 }
 
+// NamedQueryCursor is a helper function for executing queries that return a
+// large collection of data. The rows are read through a server-side cursor,
+// fetchSize at a time, and handed to fn one by one so memory stays bounded.
+// The cursor runs within a read-only transaction unless db is already one.
+// Iteration stops when the context is canceled or fn returns an error. The
+// duration recorded for the call leaves out the time spent in fn.
+func NamedQueryCursor[T any](ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, fetchSize int, fn func(T) error) (err error) {
+	ctx, c := startCall(ctx, "database.NamedQueryCursor", query, data)
+	defer func() { c.end(ctx, log, err) }()
+
+	userFn := fn
+	fn = func(v T) error {
+		start := time.Now()
+		defer func() { c.exclude(time.Since(start)) }()
+
+		return userFn(v)
+	}
+
+	named, args, err := sqlx.Named(query, data)
+	if err != nil {
+		return err
+	}
+
+	if fetchSize <= 0 {
+		fetchSize = 500
+	}
+
+	switch db := db.(type) {
+	case *sqlx.Tx:
+		return queryCursor(ctx, db, named, args, fetchSize, fn)
+
+	case *sqlx.DB:
+		tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return fmt.Errorf("begin tran: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := queryCursor(ctx, tx, named, args, fetchSize, fn); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	return fmt.Errorf("cursor requires a database or a transaction, got %T", db)
+}
+
+// cursorID is used to give every cursor a unique name.
+var cursorID atomic.Uint64
+
+func queryCursor[T any](ctx context.Context, tx *sqlx.Tx, query string, args []any, fetchSize int, fn func(T) error) error {
+	name := fmt.Sprintf("query_cursor_%d", cursorID.Add(1))
+
+	if _, err := tx.ExecContext(ctx, "DECLARE "+name+" NO SCROLL CURSOR FOR "+tx.Rebind(query), args...); err != nil {
+		return pgError(ctx, err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", fetchSize, name)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := fetchCursor(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+
+		if n < fetchSize {
+			break
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "CLOSE "+name); err != nil {
+		return pgError(ctx, err)
+	}
+
+	return nil
+}
+
+// fetchCursor runs a single FETCH against the cursor and returns the number of
+// rows that were handed to fn.
+func fetchCursor[T any](ctx context.Context, tx *sqlx.Tx, fetch string, fn func(T) error) (int, error) {
+	rows, err := tx.QueryxContext(ctx, fetch)
+	if err != nil {
+		return 0, pgError(ctx, err)
+	}
+	defer rows.Close()
+
+	var n int
+	for rows.Next() {
+		v := new(T)
+		if err := rows.StructScan(v); err != nil {
+			return n, err
+		}
+
+		if err := fn(*v); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, pgError(ctx, err)
+	}
+
+	return n, nil
+}
+
 // QueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type where field replacement is necessary.
-func QueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, dest any) error {
+func QueryStruct(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, dest any) error {
 	return namedQueryStruct(ctx, log, db, query, struct{}{}, dest, false)
 }
 
 // NamedQueryStruct is a helper function for executing queries that return a
 // single value to be unmarshalled into a struct type where field replacement is necessary.
-func NamedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest any) error {
+func NamedQueryStruct(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest any) error {
 	return namedQueryStruct(ctx, log, db, query, data, dest, false)
 }
 
 // NamedQueryStructUsingIn is a helper function for executing queries that return
 // a single value to be unmarshalled into a struct type where field replacement
 // is necessary. Use this if the query has an IN clause.
-func NamedQueryStructUsingIn(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest any) error {
+func NamedQueryStructUsingIn(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest any) error {
 	return namedQueryStruct(ctx, log, db, query, data, dest, true)
 }
 
-func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtContext, query string, data any, dest any, withIn bool) error {
-	q := queryString(query, data)
-
-	log.WithOptions(zap.AddCallerSkip(3)).Infow("database.NamedQueryStruct", "trace_id", web.GetTraceID(ctx), "query", q)
+func namedQueryStruct(ctx context.Context, log *logger.Logger, db sqlx.ExtContext, query string, data any, dest any, withIn bool) (err error) {
+	ctx, c := startCall(ctx, "database.NamedQueryStruct", query, data)
+	defer func() { c.end(ctx, log, err) }()
 
 	var rows *sqlx.Rows
-	var err error
 
 	switch withIn {
 	case true:
@@ -295,14 +399,16 @@ func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 	}
 
 	if err != nil {
-		if pqerr, ok := err.(*pgconn.PgError); ok && pqerr.Code == undefinedTable {
-			return ErrUndefinedTable
-		}
-		return err
+		return pgError(ctx, err)
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
+		// Errors raised while executing the statement, like a unique
+		// violation on an UPDATE ... RETURNING, surface here.
+		if err := rows.Err(); err != nil {
+			return pgError(ctx, err)
+		}
 		return ErrDBNotFound
 	}
 
@@ -313,7 +419,32 @@ func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 	return nil
 }
 
+// pgError maps the postgres errors we care about to the package errors. When
+// the context is done, the error is wrapped with the context error so callers
+// can tell a canceled query from a failed one.
+func pgError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	var pqerr *pgconn.PgError
+	if !errors.As(err, &pqerr) {
+		return err
+	}
+
+	switch pqerr.Code {
+	case undefinedTable:
+		return ErrUndefinedTable
+	case uniqueViolation:
+		return ErrDBDuplicatedEntry
+	}
+
+	return err
+}
+
 // queryString provides a pretty print version of the query and parameters.
+// Text values are masked since they can hold personal data, like emails or
+// password hashes.
 // this func is inefficient!
 func queryString(query string, args any) string {
 	query, params, err := sqlx.Named(query, args)
@@ -324,10 +455,8 @@ func queryString(query string, args any) string {
 	for _, param := range params {
 		var value string
 		switch v := param.(type) {
-		case string:
-			value = fmt.Sprintf("'%s'", v)
-		case []byte:
-			value = fmt.Sprintf("'%s'", string(v))
+		case string, []byte:
+			value = "'***'"
 		default:
 			value = fmt.Sprintf("%v", v)
 		}
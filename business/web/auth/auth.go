@@ -8,10 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/open-policy-agent/opa/rego"
-	"go.uber.org/zap"
 	"strings"
 	"sync"
 )
@@ -38,7 +38,7 @@ type KeyLookup interface {
 
 // Config represents information required to initialize auth.
 type Config struct {
-	Log       *zap.SugaredLogger
+	Log       *logger.Logger
 	KeyLookup KeyLookup
 	Issuer    string
 }
@@ -46,7 +46,7 @@ type Config struct {
 // Auth is used to authenticate clients. It can generate a token for a
 // set of user claims and recreate the claims by parsing the token.
 type Auth struct {
-	log       *zap.SugaredLogger
+	log       *logger.Logger
 	keyLookup KeyLookup
 	method    jwt.SigningMethod
 	parser    *jwt.Parser
@@ -58,9 +58,16 @@ type Auth struct {
 	/* We have a cache because every API call is gonna need the key, because any API call that has to do authentication, needs to go
 	through this process. If we don't cache the keys, we would have a network call to get the key from vault or sth.*/
 	cache map[string]string
+
+	// queries holds the OPA query of every rule, compiled once. policyErr is
+	// the error of compiling them, reported by the Checker.
+	queries   map[string]rego.PreparedEvalQuery
+	policyErr error
 }
 
-// New creates an Auth to support authentication/authorization.
+// New creates an Auth to support authentication/authorization. The OPA
+// policies are compiled once, a policy that doesn't compile fails the
+// Checker and every authentication and authorization.
 func New(cfg Config) (*Auth, error) {
 	a := Auth{
 		log:       cfg.Log,
@@ -71,6 +78,8 @@ func New(cfg Config) (*Auth, error) {
 		cache:     make(map[string]string),
 	}
 
+	a.queries, a.policyErr = prepareQueries(context.Background())
+
 	return &a, nil
 }
 
@@ -134,7 +143,7 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 		"ISS":   a.issuer,
 	}
 
-	if err := a.opaPolicyEvaluation(ctx, opaAuthentication, RuleAuthenticate, input); err != nil {
+	if err := a.opaPolicyEvaluation(ctx, RuleAuthenticate, input); err != nil {
 		return Claims{}, fmt.Errorf("authentication failed : %w", err)
 	}
 
@@ -157,7 +166,7 @@ func (a *Auth) Authorize(ctx context.Context, claims Claims, userID uuid.UUID, r
 		"UserID":  userID,
 	}
 
-	if err := a.opaPolicyEvaluation(ctx, opaAuthorization, rule, input); err != nil {
+	if err := a.opaPolicyEvaluation(ctx, rule, input); err != nil {
 		return fmt.Errorf("rego evaluation failed : %w", err)
 	}
 
@@ -202,17 +211,51 @@ func (a *Auth) publicKeyLookup(kid string) (string, error) {
 	return pem, nil
 }
 
-// opaPolicyEvaluation asks opa to evaluate the token against the specified token
-// policy and public key.
-func (a *Auth) opaPolicyEvaluation(ctx context.Context, opaPolicy string, rule string, input any) error {
-	query := fmt.Sprintf("x = data.%s.%s", opaPackage, rule)
+// Checker returns a health check that fails when the OPA policies didn't
+// compile, for the readiness checks.
+func (a *Auth) Checker() func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return a.policyErr
+	}
+}
 
-	q, err := rego.New(
-		rego.Query(query),
-		rego.Module("policy.rego", opaPolicy),
-	).PrepareForEval(ctx)
-	if err != nil {
-		return err
+// prepareQueries compiles the query of every rule against its policy.
+func prepareQueries(ctx context.Context) (map[string]rego.PreparedEvalQuery, error) {
+	policies := map[string]string{
+		RuleAuthenticate:   opaAuthentication,
+		RuleAny:            opaAuthorization,
+		RuleAdminOnly:      opaAuthorization,
+		RuleUserOnly:       opaAuthorization,
+		RuleAdminOrSubject: opaAuthorization,
+	}
+
+	queries := make(map[string]rego.PreparedEvalQuery, len(policies))
+
+	for rule, policy := range policies {
+		q, err := rego.New(
+			rego.Query(fmt.Sprintf("x = data.%s.%s", opaPackage, rule)),
+			rego.Module("policy.rego", policy),
+		).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("compiling policy of rule %s: %w", rule, err)
+		}
+
+		queries[rule] = q
+	}
+
+	return queries, nil
+}
+
+// opaPolicyEvaluation asks opa to evaluate the input against the query
+// compiled for the rule.
+func (a *Auth) opaPolicyEvaluation(ctx context.Context, rule string, input any) error {
+	if a.policyErr != nil {
+		return a.policyErr
+	}
+
+	q, exists := a.queries[rule]
+	if !exists {
+		return fmt.Errorf("unknown rule %q", rule)
 	}
 
 	results, err := q.Eval(ctx, rego.EvalInput(input))
@@ -0,0 +1,36 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/user"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func Test_Authorize(t *testing.T) {
+	a, err := auth.New(auth.Config{Issuer: "service project"})
+	if err != nil {
+		t.Fatalf("Should be able to construct auth : %s", err)
+	}
+
+	ctx := context.Background()
+
+	if err := a.Checker()(ctx); err != nil {
+		t.Fatalf("Should compile the policies : %s", err)
+	}
+
+	admin := auth.Claims{Roles: []user.Role{user.RoleAdmin}}
+	usr := auth.Claims{Roles: []user.Role{user.RoleUser}}
+
+	if err := a.Authorize(ctx, admin, uuid.New(), auth.RuleAdminOnly); err != nil {
+		t.Fatalf("Should authorize an admin : %s", err)
+	}
+	if err := a.Authorize(ctx, usr, uuid.New(), auth.RuleAdminOnly); err == nil {
+		t.Fatalf("Should NOT authorize a user for an admin only rule.")
+	}
+	if err := a.Authorize(ctx, admin, uuid.New(), "rule_unknown"); err == nil {
+		t.Fatalf("Should NOT authorize against an unknown rule.")
+	}
+}
@@ -4,9 +4,11 @@ package checkgrp
 import (
 	"context"
 	"encoding/json"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbmigrate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
 	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 	"net/http"
 	"os"
 	"time"
@@ -15,35 +17,67 @@ import (
 // Handlers manages the set of check endpoints.
 type Handlers struct {
 	Build string
-	Log   *zap.SugaredLogger
+	Log   *logger.Logger
 	DB    *sqlx.DB
+
+	// Checks are run by Readiness.
+	Checks *Registry
 }
 
-// Readiness checks if the database is ready and if not will return a 500 status.
+// Readiness runs the registered checks and if a critical one fails will
+// return a 500 status. A failing check that isn't critical only reports the
+// service as degraded. Once the service is shutting down it returns a 503
+// status without running the checks.
 // Do not respond by just returning an error because further up in the call
 // stack it will interpret that as a non-trusted error.
 func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
-	defer cancel()
+	if h.Checks.Draining() {
+		data := struct {
+			Status string `json:"status"`
+		}{
+			Status: "shutting down",
+		}
+
+		statusCode := http.StatusServiceUnavailable
+		if err := response(w, statusCode, data); err != nil {
+			h.Log.Error(r.Context(), "readiness", "ERROR", err)
+		}
+
+		h.Log.Info(r.Context(), "readiness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+		return
+	}
+
+	results, ok := h.Checks.Run(r.Context())
 
 	status := "ok"
 	statusCode := http.StatusOK
-	if err := database.StatusCheck(ctx, h.DB); err != nil {
-		status = "db not ready"
+	switch {
+	case !ok:
+		status = "not ready"
 		statusCode = http.StatusInternalServerError
+
+	default:
+		for _, result := range results {
+			if result.Error != "" {
+				status = "degraded"
+				break
+			}
+		}
 	}
 
 	data := struct {
-		Status string `json:"status"`
+		Status string            `json:"status"`
+		Checks map[string]Result `json:"checks"`
 	}{
 		Status: status,
+		Checks: results,
 	}
 
 	if err := response(w, statusCode, data); err != nil {
-		h.Log.Errorw("readiness", "ERROR", err)
+		h.Log.Error(r.Context(), "readiness", "ERROR", err)
 	}
 
-	h.Log.Infow("readiness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+	h.Log.Info(r.Context(), "readiness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
 }
 
 // Liveness returns simple status info if the service is alive. If the
@@ -78,12 +112,80 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 
 	statusCode := http.StatusOK
 	if err := response(w, statusCode, data); err != nil {
-		h.Log.Errorw("liveness", "ERROR", err)
+		h.Log.Error(r.Context(), "liveness", "ERROR", err)
 	}
 
 	// THIS IS A FREE TIMER. WE COULD UPDATE THE METRIC GOROUTINE COUNT HERE.
+	metrics.SetDBStats(h.DB.Stats())
+
+	h.Log.Info(r.Context(), "liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
 
-	h.Log.Infow("liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+// Startup checks that the migrations were applied and the database answers,
+// so Kubernetes holds off the other probes until the service can work. A
+// failing check returns a 500 status.
+func (h Handlers) Startup(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	status := "ok"
+	statusCode := http.StatusOK
+	// Not errors, every rollout fails the probe until the database is ready
+	// and they would raise alerts.
+	if err := database.StatusCheck(ctx, h.DB); err != nil {
+		h.Log.Warn(r.Context(), "startup", "status", "db not ready", "ERROR", err)
+		status = "db not ready"
+		statusCode = http.StatusInternalServerError
+	} else if err := dbmigrate.Verify(ctx, h.DB); err != nil {
+		h.Log.Warn(r.Context(), "startup", "status", "migrations not verified", "ERROR", err)
+		status = "migrations not verified"
+		statusCode = http.StatusInternalServerError
+	}
+
+	data := struct {
+		Status string `json:"status"`
+	}{
+		Status: status,
+	}
+
+	if err := response(w, statusCode, data); err != nil {
+		h.Log.Error(r.Context(), "startup", "ERROR", err)
+	}
+
+	h.Log.Info(r.Context(), "startup", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
+}
+
+// DBStats reports the statistics of the database connection pool, to tell
+// whether the pool is saturated.
+func (h Handlers) DBStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.DB.Stats()
+	metrics.SetDBStats(stats)
+
+	data := struct {
+		MaxOpen           int    `json:"maxOpen"`
+		Open              int    `json:"open"`
+		InUse             int    `json:"inUse"`
+		Idle              int    `json:"idle"`
+		WaitCount         int64  `json:"waitCount"`
+		WaitDuration      string `json:"waitDuration"`
+		MaxIdleClosed     int64  `json:"maxIdleClosed"`
+		MaxIdleTimeClosed int64  `json:"maxIdleTimeClosed"`
+		MaxLifetimeClosed int64  `json:"maxLifetimeClosed"`
+	}{
+		MaxOpen:           stats.MaxOpenConnections,
+		Open:              stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDuration:      stats.WaitDuration.String(),
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxIdleTimeClosed: stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+	}
+
+	if err := response(w, http.StatusOK, data); err != nil {
+		h.Log.Error(r.Context(), "dbstats", "ERROR", err)
+	}
 }
 
 func response(w http.ResponseWriter, statusCode int, data any) error {
@@ -0,0 +1,129 @@
+package checkgrp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/dbtest"
+	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/debug/checkgrp"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/docker"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var c *docker.Container
+
+func TestMain(m *testing.M) {
+	var err error
+	c, err = dbtest.StartDB()
+	if err != nil {
+		fmt.Println(err)
+		m.Run()
+		return
+	}
+	defer dbtest.StopDB(c)
+
+	m.Run()
+}
+
+func Test_StartupDBNotReady(t *testing.T) {
+	// Nothing listens on the port, the connection is refused.
+	db, err := database.Open(database.Config{User: "postgres", Password: "postgres", Host: "127.0.0.1:1", Name: "postgres", DisableTLS: true})
+	if err != nil {
+		t.Fatalf("Should be able to open the database : %s", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	h := checkgrp.Handlers{Log: logger.New(logger.Config{Writer: &buf}), DB: db}
+
+	status, code := startup(t, h)
+
+	if code != http.StatusInternalServerError || status != "db not ready" {
+		t.Fatalf("Should report the database as not ready : got %d %q", code, status)
+	}
+	if !strings.Contains(buf.String(), `"level":"WARN"`) || !strings.Contains(buf.String(), `"status":"db not ready"`) {
+		t.Fatalf("Should log why the database isn't ready : %s", buf.String())
+	}
+	if strings.Contains(buf.String(), `"level":"ERROR"`) {
+		t.Fatalf("Should NOT log an error that raises an alert : %s", buf.String())
+	}
+}
+
+func Test_Startup(t *testing.T) {
+	if c == nil {
+		t.Skip("database is not available")
+	}
+
+	log, db, teardown := dbtest.NewUnit(t, c, "teststartup")
+	t.Cleanup(teardown)
+
+	h := checkgrp.Handlers{Log: log, DB: db}
+
+	if status, code := startup(t, h); code != http.StatusOK || status != "ok" {
+		t.Fatalf("Should report a migrated database as ready : got %d %q", code, status)
+	}
+
+	const q = `DELETE FROM darwin_migrations WHERE version = (SELECT MAX(version) FROM darwin_migrations)`
+	if _, err := db.ExecContext(context.Background(), q); err != nil {
+		t.Fatalf("Should be able to forget the last migration : %s", err)
+	}
+
+	if status, code := startup(t, h); code != http.StatusInternalServerError || status != "migrations not verified" {
+		t.Fatalf("Should report the pending migrations : got %d %q", code, status)
+	}
+}
+
+func Test_DBStats(t *testing.T) {
+	db, err := database.Open(database.Config{Host: "127.0.0.1:1", DisableTLS: true, MaxOpenConns: 7})
+	if err != nil {
+		t.Fatalf("Should be able to open the database : %s", err)
+	}
+	defer db.Close()
+
+	h := checkgrp.Handlers{Log: logger.New(logger.Config{Writer: &bytes.Buffer{}}), DB: db}
+
+	w := httptest.NewRecorder()
+	h.DBStats(w, httptest.NewRequest(http.MethodGet, "/debug/dbstats", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Should respond with the statistics : got %d", w.Code)
+	}
+
+	var stats struct {
+		MaxOpen      int    `json:"maxOpen"`
+		Open         int    `json:"open"`
+		WaitDuration string `json:"waitDuration"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Should respond with JSON : %s", err)
+	}
+
+	if stats.MaxOpen != 7 || stats.Open != 0 || stats.WaitDuration != "0s" {
+		t.Fatalf("Should report the statistics of the pool : got %+v", stats)
+	}
+}
+
+// startup calls the startup probe and returns the status it reported.
+func startup(t *testing.T, h checkgrp.Handlers) (string, int) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	h.Startup(w, httptest.NewRequest(http.MethodGet, "/debug/startup", nil).WithContext(ctx))
+
+	var data struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf("Should respond with JSON : %s", err)
+	}
+
+	return data.Status, w.Code
+}
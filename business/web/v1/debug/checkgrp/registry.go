@@ -0,0 +1,155 @@
+package checkgrp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCheckTimeout bounds a check registered without a timeout.
+const defaultCheckTimeout = time.Second
+
+// CheckFunc checks that a dependency of the service is healthy. The packages
+// of the dependencies provide them, like database.Checker.
+type CheckFunc func(ctx context.Context) error
+
+// Check represents a named health check.
+type Check struct {
+	Name string
+
+	// Critical checks fail the readiness of the service, the others only
+	// report the service as degraded.
+	Critical bool
+
+	// Timeout bounds the check. It defaults to a second.
+	Timeout time.Duration
+
+	Func CheckFunc
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Registry holds the checks run to decide whether the service is ready.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   []Check
+	draining atomic.Bool
+}
+
+// NewRegistry constructs an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a check to the registry. A check with the name of an already
+// registered check replaces it.
+func (r *Registry) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultCheckTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.checks {
+		if r.checks[i].Name == check.Name {
+			r.checks[i] = check
+			return
+		}
+	}
+
+	r.checks = append(r.checks, check)
+}
+
+// Drain marks the service as shutting down, so it stops being ready no matter
+// what the checks report and Kubernetes stops routing traffic to it.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Draining reports whether Drain was called.
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Run runs the checks concurrently, each within its own timeout, and returns
+// the results by check name. ok is false when a critical check failed.
+func (r *Registry) Run(ctx context.Context) (results map[string]Result, ok bool) {
+	r.mu.RLock()
+	checks := make([]Check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	res := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+
+	for i, check := range checks {
+		go func(i int, check Check) {
+			defer wg.Done()
+			res[i] = run(ctx, check)
+		}(i, check)
+	}
+
+	wg.Wait()
+
+	results = make(map[string]Result, len(checks))
+	ok = true
+
+	for i, check := range checks {
+		results[check.Name] = res[i]
+		if check.Critical && res[i].Error != "" {
+			ok = false
+		}
+	}
+
+	return results, ok
+}
+
+// run runs a single check. A check that doesn't return once its timeout
+// passed is reported as failed and left to finish on its own.
+func run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	errs := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				errs <- fmt.Errorf("PANIC [%v]", rec)
+			}
+		}()
+		errs <- check.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:   "ok",
+		Critical: check.Critical,
+		Duration: time.Since(start).String(),
+	}
+
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package checkgrp_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/debug/checkgrp"
+	"testing"
+	"time"
+)
+
+func Test_Registry(t *testing.T) {
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name   string
+		checks []checkgrp.Check
+		ok     bool
+		failed []string
+	}{
+		{
+			name: "healthy",
+			checks: []checkgrp.Check{
+				{Name: "database", Critical: true, Func: func(context.Context) error { return nil }},
+				{Name: "cache", Func: func(context.Context) error { return nil }},
+			},
+			ok: true,
+		},
+		{
+			name: "degraded",
+			checks: []checkgrp.Check{
+				{Name: "database", Critical: true, Func: func(context.Context) error { return nil }},
+				{Name: "cache", Func: func(context.Context) error { return errors.New("down") }},
+			},
+			ok:     true,
+			failed: []string{"cache"},
+		},
+		{
+			name: "critical",
+			checks: []checkgrp.Check{
+				{Name: "database", Critical: true, Func: func(context.Context) error { return errors.New("down") }},
+				{Name: "cache", Func: func(context.Context) error { return nil }},
+			},
+			ok:     false,
+			failed: []string{"database"},
+		},
+		{
+			name: "timeout",
+			checks: []checkgrp.Check{
+				{Name: "database", Critical: true, Timeout: 50 * time.Millisecond, Func: slow},
+				{Name: "keystore", Critical: true, Timeout: 50 * time.Millisecond, Func: slow},
+			},
+			ok:     false,
+			failed: []string{"database", "keystore"},
+		},
+		{
+			name: "panic",
+			checks: []checkgrp.Check{
+				{Name: "database", Critical: true, Func: func(context.Context) error { panic("boom") }},
+			},
+			ok:     false,
+			failed: []string{"database"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := checkgrp.NewRegistry()
+			for _, check := range tt.checks {
+				reg.Register(check)
+			}
+
+			start := time.Now()
+			results, ok := reg.Run(context.Background())
+
+			// The checks run concurrently, so two timeouts take as long as one.
+			if d := time.Since(start); d > 500*time.Millisecond {
+				t.Fatalf("Should run the checks concurrently : took %s", d)
+			}
+
+			if ok != tt.ok {
+				t.Fatalf("Should report ok=%t : got %t : %+v", tt.ok, ok, results)
+			}
+			if len(results) != len(tt.checks) {
+				t.Fatalf("Should report every check : got %d", len(results))
+			}
+
+			var failed int
+			for _, name := range tt.failed {
+				if results[name].Status != "failed" || results[name].Error == "" {
+					t.Fatalf("Should report %s as failed : got %+v", name, results[name])
+				}
+				failed++
+			}
+			for name, result := range results {
+				if result.Status == "failed" {
+					failed--
+				}
+				if result.Critical != (name == "database" || name == "keystore") {
+					t.Fatalf("Should report whether %s is critical : got %t", name, result.Critical)
+				}
+			}
+			if failed != 0 {
+				t.Fatalf("Should only report the failing checks as failed : %+v", results)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package debug
 
 import (
 	"expvar"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
+	database "github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/database/pgx"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/debug/checkgrp"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/jmoiron/sqlx"
-	"go.uber.org/zap"
 	"net/http"
 	"net/http/pprof"
 )
@@ -33,16 +35,33 @@ func StandardLibraryMux() *http.ServeMux {
 // debug application routes for the service. This bypassing the use of the
 // DefaultServerMux. Using the DefaultServerMux would be a security risk since
 // a dependency could inject a handler into our service without us knowing it.
-func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+// Readiness runs the checks of the registry, the database is checked when
+// it's nil. The level of the logger is read and changed with GET and PUT on
+// /debug/loglevel.
+func Mux(build string, log *logger.Logger, db *sqlx.DB, checks *checkgrp.Registry) http.Handler {
 	mux := StandardLibraryMux()
 
+	if checks == nil {
+		checks = checkgrp.NewRegistry()
+		checks.Register(checkgrp.Check{Name: "database", Critical: true, Func: database.Checker(db)})
+	}
+
 	cgh := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build:  build,
+		Log:    log,
+		DB:     db,
+		Checks: checks,
 	}
 	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+	mux.HandleFunc("/debug/startup", cgh.Startup)
+	mux.HandleFunc("/debug/dbstats", cgh.DBStats)
+
+	// Takes and returns {"level":"debug"}.
+	mux.Handle("/debug/loglevel", log.LevelHandler())
+
+	// The same counters as /debug/vars, in a format Prometheus can scrape.
+	mux.Handle("/metrics", metrics.Handler(db))
 
 	return mux
 }
@@ -0,0 +1,135 @@
+// Package fields provides support for sparse fieldsets, letting clients ask
+// for a subset of the fields of a model with the fields query parameter.
+package fields
+
+import (
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Set represents the list of JSON fields requested by the client. An empty
+// set means all fields.
+type Set []string
+
+// IsAll reports whether all fields of the model are requested.
+func (s Set) IsAll() bool {
+	return len(s) == 0
+}
+
+// Has reports whether the field is part of the set.
+func (s Set) Has(field string) bool {
+	if s.IsAll() {
+		return true
+	}
+
+	for _, f := range s {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// =============================================================================
+
+// Parse parses the fields query parameter in the form of "id,name,email". Each
+// field is validated against the JSON fields of the model T.
+func Parse[T any](r *http.Request) (Set, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+
+	known := jsonFields(reflect.TypeOf(*new(T)))
+
+	var set Set
+	seen := make(map[string]bool)
+
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if _, exists := known[field]; !exists {
+			return nil, validate.NewFieldsError("fields", errors.New("unknown field "+field))
+		}
+
+		if !seen[field] {
+			seen[field] = true
+			set = append(set, field)
+		}
+	}
+
+	return set, nil
+}
+
+// Select returns the value with only the fields in the set. The value is
+// returned as is when all fields are requested.
+func Select[T any](s Set, v T) any {
+	if s.IsAll() {
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	known := jsonFields(rv.Type())
+
+	m := make(map[string]any, len(s))
+	for _, field := range s {
+		if idx, exists := known[field]; exists {
+			m[field] = rv.Field(idx).Interface()
+		}
+	}
+
+	return m
+}
+
+// SelectSlice applies Select to every value of the slice.
+func SelectSlice[T any](s Set, vs []T) []any {
+	items := make([]any, len(vs))
+	for i, v := range vs {
+		items[i] = Select(s, v)
+	}
+
+	return items
+}
+
+// jsonFields returns the JSON field names of a struct type mapped to the index
+// of the struct field. Fields that are not marshaled are skipped.
+func jsonFields(t reflect.Type) map[string]int {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	m := make(map[string]int)
+	if t.Kind() != reflect.Struct {
+		return m
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+
+		m[name] = i
+	}
+
+	return m
+}
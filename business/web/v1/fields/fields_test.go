@@ -0,0 +1,117 @@
+package fields_test
+
+import (
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/fields"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type model struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Email    string  `json:"email,omitempty"`
+	Address  address `json:"address"`
+	Password string  `json:"-"`
+	Plain    string
+	internal string
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		exp    fields.Set
+	}{
+		{"missing", "", nil},
+		{"empty list", ",, ,", nil},
+		{"single", "id", fields.Set{"id"}},
+		{"order and spaces", "name, id", fields.Set{"name", "id"}},
+		{"duplicates", "id,name,id", fields.Set{"id", "name"}},
+		{"tag options", "email", fields.Set{"email"}},
+		{"untagged", "Plain", fields.Set{"Plain"}},
+		{"nested struct as a whole", "address", fields.Set{"address"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := fields.Parse[model](newRequest(tt.fields))
+			if err != nil {
+				t.Fatalf("Should be able to parse the fields : %s", err)
+			}
+			if !reflect.DeepEqual(set, tt.exp) {
+				t.Fatalf("Should get back the set : exp %v got %v", tt.exp, set)
+			}
+		})
+	}
+}
+
+func Test_ParseUnknown(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+	}{
+		{"unknown", "id,age"},
+		{"nested path", "address.city"},
+		{"skipped by json", "Password"},
+		{"unexported", "internal"},
+		{"go name of a tagged field", "Name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fields.Parse[model](newRequest(tt.fields))
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 || fe[0].Field != "fields" {
+				t.Fatalf("Should reject the fields with a field error : %v", err)
+			}
+		})
+	}
+}
+
+func Test_Select(t *testing.T) {
+	m := model{ID: "1", Name: "Bill", Email: "bill@ardanlabs.com", Address: address{City: "Miami"}, Password: "secret"}
+
+	if got := fields.Select(nil, m); !reflect.DeepEqual(got, m) {
+		t.Fatalf("Should return the value as is for all fields : got %v", got)
+	}
+
+	exp := map[string]any{"id": "1", "address": address{City: "Miami"}}
+
+	if got := fields.Select(fields.Set{"id", "address"}, m); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Should select the fields : exp %v got %v", exp, got)
+	}
+	if got := fields.Select(fields.Set{"id", "address"}, &m); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("Should select the fields of a pointer : exp %v got %v", exp, got)
+	}
+
+	items := fields.SelectSlice(fields.Set{"name"}, []model{m, {Name: "Ed"}})
+	if len(items) != 2 || !reflect.DeepEqual(items[1], map[string]any{"name": "Ed"}) {
+		t.Fatalf("Should select the fields of every item : got %v", items)
+	}
+}
+
+func Test_SetHas(t *testing.T) {
+	var all fields.Set
+	if !all.IsAll() || !all.Has("anything") {
+		t.Fatalf("Should have every field in an empty set.")
+	}
+
+	s := fields.Set{"id"}
+	if s.IsAll() || !s.Has("id") || s.Has("name") {
+		t.Fatalf("Should only have the fields of the set : %v", s)
+	}
+}
+
+// newRequest constructs a request with the fields query parameter.
+func newRequest(v string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/users?"+url.Values{"fields": {v}}.Encode(), nil)
+}
@@ -8,6 +8,10 @@ import (
 	"net/http"
 )
 
+// DebugHeader is the header an admin sets to true to have the request logged
+// at the debug level.
+const DebugHeader = "X-Debug-Log"
+
 // Authenticate validates a JWT from the `Authorization` header.
 func Authenticate(a *auth.Auth) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
@@ -21,6 +25,13 @@ func Authenticate(a *auth.Auth) web.Middleware {
 			need this, we're talking about the application layer needing it*/
 			ctx = auth.SetClaims(ctx, claims)
 
+			// Admins can ask for their request to be logged in detail.
+			if r.Header.Get(DebugHeader) == "true" {
+				if err := a.Authorize(ctx, claims, uuid.UUID{}, auth.RuleAdminOnly); err == nil {
+					web.GetValues(ctx).Debug = true
+				}
+			}
+
 			return handler(ctx, w, r)
 		}
 
@@ -0,0 +1,310 @@
+package mid
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressConfig represents the settings of the Compress middleware.
+type CompressConfig struct {
+	// MinSize is the smallest body, in bytes, worth compressing. It defaults
+	// to 1024.
+	MinSize int
+
+	// Brotli allows the br encoding when the client prefers it over gzip.
+	Brotli bool
+}
+
+// compressibleTypes are the media types worth compressing. Other types, like
+// images or archives, are usually compressed already.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/x-ndjson":   true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+var brotliWriters = sync.Pool{
+	New: func() any {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	},
+}
+
+// Compress compresses response bodies with gzip, or brotli when enabled,
+// depending on the Accept-Encoding header of the request. Bodies smaller
+// than MinSize, of types that don't compress well or that already have a
+// Content-Encoding are sent as they are. The status code is passed through
+// untouched so the status in the context stays accurate.
+func Compress(log *logger.Logger, cfg CompressConfig) web.Middleware {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Brotli)
+			if encoding == "" || r.Method == http.MethodHead {
+				return handler(ctx, w, r)
+			}
+
+			cw := compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        cfg.MinSize,
+			}
+
+			err := handler(ctx, &cw, r)
+
+			// The response is already sent, failing to finish it is the
+			// client going away or being slow. It's no reason to shut the
+			// service down, which an error returned here could do.
+			if cerr := cw.Close(); cerr != nil {
+				log.Warn(ctx, "compress", "status", "finishing the response", "encoding", encoding, "ERROR", cerr)
+			}
+
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// negotiateEncoding picks the encoding from the Accept-Encoding header. Brotli
+// wins a tie with gzip when it's allowed. A "*" stands for gzip unless the
+// client named it. Codings with a q of 0 are refused by the client. An empty
+// string means no encoding.
+func negotiateEncoding(acceptEncoding string, allowBrotli bool) string {
+	accepted := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		accepted[coding] = q
+	}
+
+	if _, exists := accepted["gzip"]; !exists {
+		if q, exists := accepted["*"]; exists {
+			accepted["gzip"] = q
+		}
+	}
+
+	var best string
+	var bestQ float64
+
+	for _, coding := range []string{"gzip", "br"} {
+		if coding == "br" && !allowBrotli {
+			continue
+		}
+
+		q, exists := accepted[coding]
+		if !exists || q <= 0 {
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && coding == "br") {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+
+	// err is the error of a write done by Flush, returned by the next Write
+	// since Flush can't return it.
+	err error
+}
+
+// WriteHeader holds the status until the first bytes of the body decide the
+// encoding, since the Content-Encoding header must be set before.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+
+	cw.status = status
+
+	// Responses without a body are sent right away.
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < http.StatusOK {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) < cw.minSize {
+		return len(b), nil
+	}
+
+	if err := cw.decide(true); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// Flush sends what is buffered so far, which streams rely on. A stream that
+// is flushed before reaching the minimum size is compressed anyway since it's
+// likely to keep going.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			cw.err = err
+			return
+		}
+	}
+
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			cw.err = err
+			return
+		}
+	}
+
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close sends a body that stayed under the minimum size and finishes the
+// compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.enc == nil {
+		return nil
+	}
+
+	err := cw.enc.Close()
+
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	case *brotli.Writer:
+		brotliWriters.Put(enc)
+	}
+	cw.enc = nil
+
+	return err
+}
+
+// Unwrap returns the original writer so http.ResponseController can reach it.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide sends the header, compressed when allowed and worth it, followed by
+// the buffered part of the body.
+func (cw *compressWriter) decide(allowCompression bool) error {
+	cw.decided = true
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if allowCompression && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+
+		switch cw.encoding {
+		case "br":
+			bw := brotliWriters.Get().(*brotli.Writer)
+			bw.Reset(cw.ResponseWriter)
+			cw.enc = bw
+		default:
+			gw := gzipWriters.Get().(*gzip.Writer)
+			gw.Reset(cw.ResponseWriter)
+			cw.enc = gw
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible reports whether the content type is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		compressibleTypes[mediaType]
+}
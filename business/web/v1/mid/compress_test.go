@@ -0,0 +1,177 @@
+package mid_test
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Compress(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Compress(log, mid.CompressConfig{MinSize: 100}))
+
+	large := strings.Repeat(`{"name":"bill"},`, 50)
+
+	respond := func(status int, body string) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+			return nil
+		}
+	}
+
+	app.Handle(http.MethodPost, "/large", respond(http.StatusCreated, large))
+	app.Handle(http.MethodGet, "/small", respond(http.StatusOK, `{"name":"bill"}`))
+	app.Handle(http.MethodGet, "/nocontent", respond(http.StatusNoContent, ""))
+	app.Handle(http.MethodGet, "/notmodified", respond(http.StatusNotModified, ""))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		status   int
+		encoding string
+		body     string
+	}{
+		{"compressed", http.MethodPost, "/large", "gzip", http.StatusCreated, "gzip", large},
+		{"not accepted", http.MethodPost, "/large", "", http.StatusCreated, "", large},
+		{"under min size", http.MethodGet, "/small", "gzip", http.StatusOK, "", `{"name":"bill"}`},
+		{"no content", http.MethodGet, "/nocontent", "gzip", http.StatusNoContent, "", ""},
+		{"not modified", http.MethodGet, "/notmodified", "gzip", http.StatusNotModified, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Should pass the status through : exp %d got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Fatalf("Should vary on Accept-Encoding : got %q", got)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Should encode with %q : got %q", tt.encoding, got)
+			}
+
+			var body io.Reader = w.Body
+			if tt.encoding == "gzip" {
+				gr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("Should be able to read the gzip body : %s", err)
+				}
+				body = gr
+			}
+
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("Should be able to read the body : %s", err)
+			}
+			if string(got) != tt.body {
+				t.Fatalf("Should send the body : got %q", got)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write, like a client that went away.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("write: broken pipe")
+}
+
+func Test_CompressCloseError(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"name":"bill"}`)
+		return nil
+	}
+
+	h := mid.Compress(log, mid.CompressConfig{})(handler)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	// The body stays buffered under MinSize, so it's written by Close.
+	if err := h(context.Background(), failingWriter{httptest.NewRecorder()}, r); err != nil {
+		t.Fatalf("Should not return the error of finishing the response : %s", err)
+	}
+}
+
+func Test_CompressNegotiation(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	large := strings.Repeat(`{"name":"bill"},`, 50)
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, large)
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		accept   string
+		brotli   bool
+		encoding string
+	}{
+		{"gzip", "gzip", true, "gzip"},
+		{"br", "br", true, "br"},
+		{"br not allowed", "br", false, ""},
+		{"br wins a tie", "gzip, br", true, "br"},
+		{"gzip without brotli", "gzip, br", false, "gzip"},
+		{"higher q", "gzip;q=1.0, br;q=0.5", true, "gzip"},
+		{"case insensitive", "GZIP;Q=0.8, BR;q=0.9", true, "br"},
+		{"br refused", "br;q=0", true, ""},
+		{"br refused with identity", "identity, br;q=0", true, ""},
+		{"br refused with gzip", "gzip, br;q=0", true, "gzip"},
+		{"gzip refused", "gzip;q=0", true, ""},
+		{"star", "*", false, "gzip"},
+		{"star refused", "*;q=0", true, ""},
+		{"star after refused gzip", "gzip;q=0, *", true, ""},
+		{"star before refused gzip", "*, gzip;q=0", true, ""},
+		{"unknown", "deflate, identity", true, ""},
+		{"invalid q", "gzip;q=high", true, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := mid.Compress(log, mid.CompressConfig{MinSize: 100, Brotli: tt.brotli})(handler)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+
+			if err := h(context.Background(), w, r); err != nil {
+				t.Fatalf("Should be able to respond : %s", err)
+			}
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Should encode %q with %q : got %q", tt.accept, tt.encoding, got)
+			}
+		})
+	}
+}
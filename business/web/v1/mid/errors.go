@@ -2,24 +2,51 @@ package mid
 
 import (
 	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
-	"go.uber.org/zap"
 	"net/http"
+	"syscall"
 )
 
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
-// Unexpected errors (status >= 500) are logged.
+// Unexpected errors (status >= 500) are logged at the error level, the others
+// at the warn level.
 // Error handling means logging the error, so we needed to pass the logger.
 // Note: Middlewares accepts the thing they need like the logger.
-func Errors(log *zap.SugaredLogger) web.Middleware {
+func Errors(log *logger.Logger) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			if err := handler(ctx, w, r); err != nil {
-				log.Errorw("ERROR", "trace_id", web.GetTraceID(ctx), "message", err)
+				metrics.AddErrors(ctx)
+
+				// Panics are logged apart from the other errors, so they
+				// raise their own alerts.
+				msg := "ERROR"
+				if errors.Is(err, ErrPanic) {
+					msg = "PANIC"
+				}
+
+				// The response was already started, like with a stream, so
+				// the error can only be logged. A client that went away
+				// isn't worth an alert.
+				if web.GetValues(ctx).StatusCode != 0 {
+					if clientGone(err) {
+						log.Warn(ctx, msg, "message", err)
+					} else {
+						log.Error(ctx, msg, "message", err)
+					}
+
+					if web.IsShutdown(err) {
+						return err
+					}
+					return nil
+				}
 
 				var er v1.ErrorResponse
 				var status int
@@ -33,6 +60,38 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					}
 					status = http.StatusBadRequest
 
+				case errors.Is(err, web.ErrBodyTooLarge):
+					er = v1.ErrorResponse{
+						Error: web.ErrBodyTooLarge.Error(),
+					}
+					status = http.StatusRequestEntityTooLarge
+
+				case errors.Is(err, web.ErrUnsupportedMediaType):
+					er = v1.ErrorResponse{
+						Error: web.ErrUnsupportedMediaType.Error(),
+					}
+					status = http.StatusUnsupportedMediaType
+
+				case errors.Is(err, web.ErrInvalidEncoding):
+					er = v1.ErrorResponse{
+						Error: web.ErrInvalidEncoding.Error(),
+					}
+					status = http.StatusBadRequest
+
+				case errors.Is(err, ErrTimeout):
+					er = v1.ErrorResponse{
+						Error: ErrTimeout.Error(),
+					}
+					status = http.StatusGatewayTimeout
+
+				// A deadline set outside the route, like the one of a
+				// dependency, means we can't serve the request right now.
+				case errors.Is(err, context.DeadlineExceeded):
+					er = v1.ErrorResponse{
+						Error: http.StatusText(http.StatusServiceUnavailable),
+					}
+					status = http.StatusServiceUnavailable
+
 				case v1.IsRequestError(err):
 					reqErr := v1.GetRequestError(err)
 					er = v1.ErrorResponse{
@@ -40,6 +99,12 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					}
 					status = reqErr.Status
 
+				case errors.Is(err, web.ErrNotAcceptable):
+					er = v1.ErrorResponse{
+						Error: http.StatusText(http.StatusNotAcceptable),
+					}
+					status = http.StatusNotAcceptable
+
 				case auth.IsAuthError(err):
 					er = v1.ErrorResponse{
 						Error: http.StatusText(http.StatusUnauthorized),
@@ -53,7 +118,17 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 					status = http.StatusInternalServerError
 				}
 
-				if err := web.Respond(ctx, w, er, status); err != nil {
+				// Errors of the client are expected, they're not worth an
+				// alert.
+				if status >= http.StatusInternalServerError {
+					log.Error(ctx, msg, "message", err)
+				} else {
+					log.Warn(ctx, msg, "message", err)
+				}
+
+				// Errors are always sent as JSON since the client may not accept
+				// any media type we support.
+				if err := web.RespondJSON(ctx, w, er, status); err != nil {
 					return err
 				}
 
@@ -75,3 +150,11 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 
 	return m
 }
+
+// clientGone reports whether the error comes from a client that canceled the
+// request or closed the connection.
+func clientGone(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,61 @@
+package mid_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func Test_ErrorsStreaming(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		level string
+	}{
+		{"canceled", fmt.Errorf("streaming: %w", context.Canceled), "WARN"},
+		{"broken pipe", fmt.Errorf("write: %w", syscall.EPIPE), "WARN"},
+		{"connection reset", fmt.Errorf("write: %w", syscall.ECONNRESET), "WARN"},
+		{"database", errors.New("database is down"), "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := logger.New(logger.Config{Writer: &buf})
+
+			// The stream started before the error.
+			handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				web.SetStatusCode(ctx, http.StatusOK)
+				w.WriteHeader(http.StatusOK)
+				return tt.err
+			}
+
+			app := web.NewApp(make(chan os.Signal, 1), mid.Errors(log))
+			app.Handle(http.MethodGet, "/export", handler)
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("Should log the error once : %s : %s", err, buf.String())
+			}
+			if entry["level"] != tt.level {
+				t.Fatalf("Should log at %s : got %v", tt.level, entry["level"])
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("Should keep the status of the stream : got %d", w.Code)
+			}
+		})
+	}
+}
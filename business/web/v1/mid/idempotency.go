@@ -0,0 +1,154 @@
+package mid
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxIdempotencyKey is the longest idempotency key that is accepted.
+const maxIdempotencyKey = 255
+
+// replayedHeaders are the response headers stored with the response so they
+// can be replayed.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency makes requests safe to retry. The first response for an
+// Idempotency-Key header and authenticated subject is stored for the ttl and
+// replayed for any repeat of the request. Reusing a key with a different
+// request is rejected. Requests without the header are not affected. Failed
+// requests aren't stored, so they can be retried with the same key. A request
+// in progress holds the key for the lease, which must outlast the request. It
+// defaults to 1m.
+func Idempotency(log *logger.Logger, core *idempotency.Core, ttl time.Duration, lease time.Duration) web.Middleware {
+	if lease <= 0 {
+		lease = time.Minute
+	}
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				return handler(ctx, w, r)
+			}
+
+			if len(key) > maxIdempotencyKey {
+				return validate.NewFieldsError("Idempotency-Key", fmt.Errorf("must be at most %d characters", maxIdempotencyKey))
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return fmt.Errorf("idempotency: reading body: %w", err)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec, err := core.Begin(ctx, key, auth.GetClaims(ctx).Subject, requestHash(r, body), lease)
+			if err != nil {
+				switch {
+				case errors.Is(err, idempotency.ErrKeyReused):
+					return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+				case errors.Is(err, idempotency.ErrInProgress):
+					return v1.NewRequestError(err, http.StatusConflict)
+				default:
+					return fmt.Errorf("idempotency: %w", err)
+				}
+			}
+
+			if !rec.IsPending() {
+				return replay(ctx, w, rec)
+			}
+
+			// The claim must be settled even when the client went away.
+			settleCtx := context.WithoutCancel(ctx)
+
+			rw := responseRecorder{ResponseWriter: w}
+
+			if err := handler(ctx, &rw, r); err != nil {
+				if err := core.Release(settleCtx, rec); err != nil {
+					log.Error(ctx, "idempotency", "key", key, "ERROR", err)
+				}
+				return err
+			}
+
+			status := web.GetValues(ctx).StatusCode
+			if status == 0 || status >= http.StatusInternalServerError {
+				if err := core.Release(settleCtx, rec); err != nil {
+					log.Error(ctx, "idempotency", "key", key, "ERROR", err)
+				}
+				return nil
+			}
+
+			header := make(http.Header)
+			for _, name := range replayedHeaders {
+				if v := w.Header().Values(name); len(v) > 0 {
+					header[name] = v
+				}
+			}
+
+			if err := core.Complete(settleCtx, rec, ttl, status, header, rw.body.Bytes()); err != nil {
+				log.Error(ctx, "idempotency", "key", key, "ERROR", err)
+			}
+
+			return nil
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// requestHash identifies a request by its method, path, query and body. The
+// query parameters are sorted, so their order doesn't matter.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.Query().Encode() + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes the stored response of the record to the client.
+func replay(ctx context.Context, w http.ResponseWriter, rec idempotency.Record) error {
+	for name, values := range rec.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+
+	web.SetStatusCode(ctx, rec.StatusCode)
+	w.WriteHeader(rec.StatusCode)
+
+	if _, err := w.Write(rec.Body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// responseRecorder keeps a copy of the response body as it's written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the original writer so http.ResponseController can reach it.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
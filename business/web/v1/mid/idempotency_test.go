@@ -0,0 +1,168 @@
+package mid_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/core/idempotency"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idempotencyStore keeps the records in memory.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+}
+
+func (s *idempotencyStore) Create(ctx context.Context, rec idempotency.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[rec.Key+rec.Subject]; exists {
+		return idempotency.ErrExists
+	}
+	s.records[rec.Key+rec.Subject] = rec
+
+	return nil
+}
+
+func (s *idempotencyStore) Update(ctx context.Context, rec idempotency.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.Key+rec.Subject] = rec
+
+	return nil
+}
+
+func (s *idempotencyStore) Delete(ctx context.Context, key string, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key+subject)
+
+	return nil
+}
+
+func (s *idempotencyStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+func (s *idempotencyStore) QueryByKey(ctx context.Context, key string, subject string) (idempotency.Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[key+subject]
+	if !exists {
+		return idempotency.Record{}, idempotency.ErrNotFound
+	}
+
+	return rec, nil
+}
+
+func Test_Idempotency(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	store := idempotencyStore{records: make(map[string]idempotency.Record)}
+	core := idempotency.NewCore(&store)
+
+	var calls int
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		calls++
+		if r.URL.Query().Get("fail") != "" {
+			return errors.New("database is down")
+		}
+		if r.URL.Query().Get("wait") != "" {
+			close(started)
+			<-release
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Location", "/users/1")
+		return web.Respond(ctx, w, map[string]string{"body": string(body)}, http.StatusCreated)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Errors(log))
+	app.Handle(http.MethodPost, "/users", handler, mid.Idempotency(log, core, time.Hour, time.Minute))
+
+	send := func(key string, query string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/users"+query, strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	first := send("k1", "?a=1&b=2", `{"name":"bill"}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("Should process the first request : got %d after %d calls", first.Code, calls)
+	}
+
+	// The order of the query parameters doesn't make another request.
+	replayed := send("k1", "?b=2&a=1", `{"name":"bill"}`)
+	if replayed.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("Should replay the response : got %d after %d calls", replayed.Code, calls)
+	}
+	if replayed.Header().Get("Idempotent-Replayed") != "true" || replayed.Header().Get("Location") != "/users/1" {
+		t.Fatalf("Should replay the headers : %v", replayed.Header())
+	}
+	if replayed.Body.String() != first.Body.String() {
+		t.Fatalf("Should replay the body : exp %s got %s", first.Body, replayed.Body)
+	}
+
+	if w := send("k1", "?a=2&b=2", `{"name":"bill"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Should reject the key used with another query : got %d", w.Code)
+	}
+	if w := send("k1", "?a=1&b=2", `{"name":"ale"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Should reject the key used with another body : got %d", w.Code)
+	}
+
+	if w := send("k2", "?fail=1", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Should fail the request : got %d", w.Code)
+	}
+	if _, err := store.QueryByKey(context.Background(), "k2", ""); !errors.Is(err, idempotency.ErrNotFound) {
+		t.Fatalf("Should release the key of a failed request : %v", err)
+	}
+
+	// A request in progress holds the key until its lease runs out.
+	done := make(chan struct{})
+	go func() {
+		send("k3", "?wait=1", "")
+		close(done)
+	}()
+	<-started
+
+	if w := send("k3", "?wait=1", ""); w.Code != http.StatusConflict {
+		t.Fatalf("Should reject the key of a request in progress : got %d", w.Code)
+	}
+	close(release)
+	<-done
+
+	now := time.Now()
+	store.Create(context.Background(), idempotency.Record{Key: "k4", DateCreated: now.Add(-time.Hour), DateExpires: now.Add(-time.Second)})
+
+	calls = 0
+	if w := send("k4", "", ""); w.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("Should claim the key once the lease ran out : got %d", w.Code)
+	}
+
+	rec, err := store.QueryByKey(context.Background(), "k4", "")
+	if err != nil {
+		t.Fatalf("Should store the response : %s", err)
+	}
+	if rec.DateExpires.Before(now.Add(59 * time.Minute)) {
+		t.Fatalf("Should keep the response for the ttl : expires %s", rec.DateExpires)
+	}
+}
@@ -3,14 +3,15 @@ package mid
 import (
 	"context"
 	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
-	"go.uber.org/zap"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // with Logger, we have the ability to do the logging before and after the handler that was called
-func Logger(log *zap.SugaredLogger) web.Middleware {
+func Logger(log *logger.Logger) web.Middleware {
 	// the idea is we want to execute the handler that was passed in but with some extra work
 
 	m := func(handler web.Handler) web.Handler {
@@ -22,13 +23,16 @@ func Logger(log *zap.SugaredLogger) web.Middleware {
 				path = fmt.Sprintf("%s?%s", path, r.URL.RawQuery)
 			}
 
-			log.Infow("request started", "trace_id", v.TraceID, "method", r.Method, "path", path, "remoteaddr", r.RemoteAddr)
+			log.Info(ctx, "request started", "method", r.Method, "path", path, "remoteaddr", r.RemoteAddr)
 
 			err := handler(ctx, w, r)
 
+			log.Debug(ctx, "request details", "route", v.Route, "headers", debugHeaders(r.Header), "contentlength", r.ContentLength,
+				"ERROR", err)
+
 			// by seeing this log, it means we don't have leaking(the goroutine created for the req is gonna complete) and also it's not blocked,
 			// because we actually saw this log
-			log.Infow("request completed", "trace_id", v.TraceID, "method", r.Method, "path", path, "remoteaddr", r.RemoteAddr,
+			log.Info(ctx, "request completed", "method", r.Method, "path", path, "remoteaddr", r.RemoteAddr,
 				"statuscode", v.StatusCode, "since", time.Since(v.Now).String())
 
 			return err
@@ -39,3 +43,19 @@ func Logger(log *zap.SugaredLogger) web.Middleware {
 
 	return m
 }
+
+// debugHeaders returns the headers of the request with the credentials
+// left out.
+func debugHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, v := range h {
+		switch k {
+		case "Authorization", "Cookie", "X-Api-Key":
+			headers[k] = "[redacted]"
+		default:
+			headers[k] = strings.Join(v, ", ")
+		}
+	}
+
+	return headers
+}
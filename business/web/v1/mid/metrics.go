@@ -5,16 +5,25 @@ import (
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
 	"net/http"
+	"time"
 )
 
-// Metrics updates program counters.
+// Metrics updates program counters and records the duration, response size
+// and status of the requests to every route. It must wrap Errors so the
+// status of failed requests is known, Errors counts the errors.
 func Metrics() web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			// put the metrics data in to the ctx
 			ctx = metrics.Set(ctx)
 
-			err := handler(ctx, w, r)
+			start := time.Now()
+			cw := countingWriter{ResponseWriter: w}
+
+			err := handler(ctx, &cw, r)
+
+			v := web.GetValues(ctx)
+			metrics.AddRoute(ctx, v.Route, r.Method, v.StatusCode, cw.n, time.Since(start))
 
 			n := metrics.AddRequests(ctx)
 
@@ -24,11 +33,6 @@ func Metrics() web.Middleware {
 				metrics.AddGoroutines(ctx)
 			}
 
-			// we're still behind the error handling(error handler middleware is a parent of this middleware), but we can update the metrics for errors
-			if err != nil {
-				metrics.AddErrors(ctx)
-			}
-
 			return err
 		}
 
@@ -37,3 +41,21 @@ func Metrics() web.Middleware {
 
 	return m
 }
+
+// countingWriter counts the bytes of the response body as they are sent.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(b)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+// Unwrap returns the original writer so http.ResponseController can reach it.
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
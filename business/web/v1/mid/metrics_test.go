@@ -0,0 +1,49 @@
+package mid_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_MetricsRoute(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Metrics(), mid.Errors(log))
+	app.Handle(http.MethodGet, "/metricstest/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if web.Param(r, "id") == "missing" {
+			return errors.New("not found")
+		}
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/metricstest/1", "/metricstest/2", "/metricstest/missing"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, line := range []string{
+		`sales_http_request_duration_seconds_count{method="GET",route="/metricstest/:id",status="2xx"} 2`,
+		`sales_http_request_duration_seconds_count{method="GET",route="/metricstest/:id",status="5xx"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Should label the requests by route pattern and status class %q : %s", line, body)
+		}
+	}
+
+	if strings.Contains(body, `route="/metricstest/1"`) {
+		t.Fatalf("Should NOT label the requests by raw path.")
+	}
+}
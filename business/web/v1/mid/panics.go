@@ -2,6 +2,7 @@ package mid
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
@@ -9,6 +10,9 @@ import (
 	"runtime/debug"
 )
 
+// ErrPanic is wrapped by the error a recovered panic is converted to.
+var ErrPanic = errors.New("PANIC")
+
 // Panics recovers from panics and converts the panic to an error so it is
 // reported in Metrics and handled in Errors.
 /* We returned a web.Middleware although we don't do anything in that middleware. This is just for consistency with other middlewares.
@@ -27,7 +31,7 @@ func Panics() web.Middleware {
 
 					/* Do not log the stacktrace HERE. The error handler(middleware) logs. Instead, here we construct an error with the
 					stacktrace in it and then that error will be received by the error middleware.*/
-					err = fmt.Errorf("PANIC [%v] TRACE[%s]", rec, string(trace))
+					err = fmt.Errorf("%w [%v] TRACE[%s]", ErrPanic, rec, string(trace))
 
 					metrics.AddPanics(ctx)
 				}
@@ -0,0 +1,144 @@
+package mid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/metrics"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/auth"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrRateLimited is returned when a client made too many requests.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitKey returns the key of the bucket a request takes its token from.
+// An empty key means the function can't identify the client.
+type RateLimitKey func(ctx context.Context, r *http.Request) string
+
+// KeyBySubject identifies the client by the subject of its claims.
+func KeyBySubject(ctx context.Context, r *http.Request) string {
+	if subject := auth.GetClaims(ctx).Subject; subject != "" {
+		return "sub:" + subject
+	}
+
+	return ""
+}
+
+// KeyByIP identifies the client by the remote address of the connection.
+func KeyByIP(ctx context.Context, r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// KeyByAPIKey identifies the client by the API key in the specified header.
+// The key is hashed so it's never held in the buckets. Only use it on routes
+// that authenticate the key, otherwise a client gets a new bucket by sending
+// a new key.
+func KeyByAPIKey(header string) RateLimitKey {
+	return func(ctx context.Context, r *http.Request) string {
+		apiKey := r.Header.Get(header)
+		if apiKey == "" {
+			return ""
+		}
+
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+// KeyFirst identifies the client with the first function that returns a key.
+func KeyFirst(keys ...RateLimitKey) RateLimitKey {
+	return func(ctx context.Context, r *http.Request) string {
+		for _, key := range keys {
+			if k := key(ctx, r); k != "" {
+				return k
+			}
+		}
+
+		return ""
+	}
+}
+
+// DefaultRateLimitKey identifies the client by its subject and falls back to
+// its IP address.
+var DefaultRateLimitKey = KeyFirst(KeyBySubject, KeyByIP)
+
+// RateLimitConfig represents the limit of a route.
+type RateLimitConfig struct {
+	// Name scopes the buckets, so routes with different names are limited
+	// separately.
+	Name  string
+	Limit ratelimit.Limit
+
+	// Key identifies the client, DefaultRateLimitKey is used when nil.
+	Key RateLimitKey
+}
+
+// RateLimit limits how often a client can call the route using a token bucket
+// held by the store. The RateLimit-* headers are set on every response and
+// a 429 with a Retry-After header is returned once the limit is reached. When
+// the store fails, the request is let through. A zero limit, or a nil store,
+// turns rate limiting off.
+func RateLimit(log *logger.Logger, store ratelimit.Store, cfg RateLimitConfig) web.Middleware {
+	if store == nil || cfg.Limit.Requests == 0 {
+		return nil
+	}
+
+	if cfg.Key == nil {
+		cfg.Key = DefaultRateLimitKey
+	}
+
+	policy := strconv.Itoa(cfg.Limit.Capacity()) + ";w=" + strconv.Itoa(int(cfg.Limit.Period.Seconds()))
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := cfg.Key(ctx, r)
+			if key == "" {
+				return handler(ctx, w, r)
+			}
+
+			res, err := store.Take(ctx, cfg.Name+":"+key, cfg.Limit, time.Now())
+			if err != nil {
+				log.Error(ctx, "ratelimit", "name", cfg.Name, "ERROR", err)
+				return handler(ctx, w, r)
+			}
+
+			w.Header().Set("RateLimit-Policy", policy)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				metrics.AddRateLimited(ctx)
+
+				w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+				return v1.NewRequestError(ErrRateLimited, http.StatusTooManyRequests)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// ceilSeconds formats the duration as a whole number of seconds, rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
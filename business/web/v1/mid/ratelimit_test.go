@@ -0,0 +1,129 @@
+package mid_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_RateLimit(t *testing.T) {
+	app := newRateLimitApp(ratelimit.NewMemory(), mid.RateLimitConfig{Name: "users", Limit: ratelimit.Limit{Requests: 2, Period: time.Minute}})
+
+	tests := []struct {
+		status    int
+		remaining string
+	}{
+		{http.StatusNoContent, "1"},
+		{http.StatusNoContent, "0"},
+		{http.StatusTooManyRequests, "0"},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("Should respond to request %d with %d : got %d", i, tt.status, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Fatalf("Should set the policy : got %q", got)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Fatalf("Should set the limit : got %q", got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != tt.remaining {
+			t.Fatalf("Should set the remaining requests to %s : got %q", tt.remaining, got)
+		}
+		if reset, err := strconv.Atoi(w.Header().Get("RateLimit-Reset")); err != nil || reset < 1 || reset > 60 {
+			t.Fatalf("Should set the reset within the period : got %q", w.Header().Get("RateLimit-Reset"))
+		}
+
+		retryAfter := w.Header().Get("Retry-After")
+		if tt.status != http.StatusTooManyRequests {
+			if retryAfter != "" {
+				t.Fatalf("Should NOT set Retry-After on an allowed request : got %q", retryAfter)
+			}
+			continue
+		}
+		if s, err := strconv.Atoi(retryAfter); err != nil || s < 1 || s > 30 {
+			t.Fatalf("Should set Retry-After to when a token is back : got %q", retryAfter)
+		}
+	}
+
+	// Another client has its own bucket.
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Should NOT limit another client : got %d", w.Code)
+	}
+}
+
+func Test_RateLimitDefaultKey(t *testing.T) {
+	store := ratelimit.NewMemory()
+	app := newRateLimitApp(store, mid.RateLimitConfig{Name: "users", Limit: ratelimit.Limit{Requests: 1, Period: time.Minute}})
+
+	// An API key nobody checked must not give the client a new bucket.
+	for i, key := range []string{"first", "second"} {
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-API-Key", key)
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if i == 1 && w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Should limit the client whatever the API key : got %d", w.Code)
+		}
+	}
+
+	if store.Len() != 1 {
+		t.Fatalf("Should hold a single bucket for the client : got %d", store.Len())
+	}
+}
+
+func Test_RateLimitStoreFailure(t *testing.T) {
+	app := newRateLimitApp(failingStore{}, mid.RateLimitConfig{Name: "users", Limit: ratelimit.Limit{Requests: 1, Period: time.Minute}})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("Should let the request through without the headers : got %d %v", w.Code, w.Header())
+	}
+}
+
+// newRateLimitApp constructs an app with a single rate limited route.
+func newRateLimitApp(store ratelimit.Store, cfg mid.RateLimitConfig) http.Handler {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Errors(log))
+	app.Handle(http.MethodGet, "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}, mid.RateLimit(log, store, cfg))
+
+	return app
+}
+
+// failingStore is a store that can't be reached.
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is down")
+}
@@ -0,0 +1,46 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"time"
+)
+
+// ErrTimeout is returned when a request ran past the timeout of its route.
+var ErrTimeout = errors.New("request timed out")
+
+// Timeout puts a deadline on the context of the request so the work it does,
+// like database queries, is canceled once the route ran for longer than d.
+// A handler failing after the deadline passed returns ErrTimeout, which
+// Errors turns into a 504. A zero duration turns the timeout off.
+func Timeout(d time.Duration) web.Middleware {
+	if d <= 0 {
+		return nil
+	}
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			parent := ctx
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			err := handler(ctx, w, r.WithContext(ctx))
+
+			// Only our own deadline is reported as a timeout, the client
+			// going away or a shorter deadline set by the caller isn't.
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil {
+				return fmt.Errorf("%w after %s: %w", ErrTimeout, d, err)
+			}
+
+			return err
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,123 @@
+package mid_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/mid"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_TimeoutDeadline(t *testing.T) {
+	const d = time.Minute
+
+	var deadline time.Time
+	var ok, requestOK bool
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		deadline, ok = ctx.Deadline()
+		_, requestOK = r.Context().Deadline()
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Timeout(d))
+	app.Handle(http.MethodGet, "/users", handler)
+
+	start := time.Now()
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if !ok || !requestOK {
+		t.Fatalf("Should put a deadline on the context and the request.")
+	}
+	if deadline.Before(start.Add(d)) || deadline.After(time.Now().Add(d)) {
+		t.Fatalf("Should set the deadline %s from the start : got %s", d, deadline.Sub(start))
+	}
+}
+
+func Test_TimeoutZero(t *testing.T) {
+	if mid.Timeout(0) != nil {
+		t.Fatalf("Should NOT construct a middleware for a zero duration.")
+	}
+
+	var ok bool
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		_, ok = ctx.Deadline()
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Timeout(0))
+	app.Handle(http.MethodGet, "/users", handler)
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if ok {
+		t.Fatalf("Should leave the route unbounded.")
+	}
+}
+
+func Test_TimeoutStatus(t *testing.T) {
+	// waitDeadline waits for the context to end and fails like a query would.
+	waitDeadline := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler web.Handler
+		status  int
+	}{
+		{"route deadline", time.Millisecond, waitDeadline, http.StatusGatewayTimeout},
+		{"other error", time.Minute, func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return errors.New("database is down")
+		}, http.StatusInternalServerError},
+		{"in time", time.Minute, func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := logger.New(logger.Config{Writer: io.Discard})
+
+			app := web.NewApp(make(chan os.Signal, 1), mid.Errors(log), mid.Timeout(tt.timeout))
+			app.Handle(http.MethodGet, "/users", tt.handler)
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("Should respond with %d : got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func Test_TimeoutCallerDeadline(t *testing.T) {
+	log := logger.New(logger.Config{Writer: io.Discard})
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), mid.Errors(log), mid.Timeout(time.Minute))
+	app.Handle(http.MethodGet, "/users", handler)
+
+	// The deadline of the caller ends first, it isn't the timeout of the route.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Should respond with %d : got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
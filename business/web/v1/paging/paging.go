@@ -2,17 +2,27 @@
 package paging
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
 	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // Response is what is returned when a query call is performed.
 type Response[T any] struct {
-	Items       []T `json:"items"`
-	Total       int `json:"total"`
-	Page        int `json:"page"`
-	RowsPerPage int `json:"rowsPerPage"`
+	Items       []T    `json:"items"`
+	Total       int    `json:"total"`
+	Page        int    `json:"page,omitempty"`
+	RowsPerPage int    `json:"rowsPerPage"`
+	NextCursor  string `json:"nextCursor,omitempty"`
+	PrevCursor  string `json:"prevCursor,omitempty"`
 }
 
 // NewResponse constructs a response value for a web response.
@@ -25,15 +35,56 @@ func NewResponse[T any](items []T, total, page, rowsPerPage int) Response[T] {
 	}
 }
 
+// NewCursorResponse constructs a response value for a web response of a
+// keyset query. The cursors are empty when there is no next or previous page.
+func NewCursorResponse[T any](items []T, total, rowsPerPage int, nextCursor string, prevCursor string) Response[T] {
+	return Response[T]{
+		Items:       items,
+		Total:       total,
+		RowsPerPage: rowsPerPage,
+		NextCursor:  nextCursor,
+		PrevCursor:  prevCursor,
+	}
+}
+
+// CollectionItems returns the items of the response. It's used when the
+// response is sent in a format other than JSON.
+func (r Response[T]) CollectionItems() any {
+	return r.Items
+}
+
+// CollectionHeaders sets the paging metadata of the response as headers. It's
+// used when the response is sent in a format other than JSON.
+func (r Response[T]) CollectionHeaders(h http.Header) {
+	h.Set("X-Total-Count", strconv.Itoa(r.Total))
+	h.Set("X-Rows-Per-Page", strconv.Itoa(r.RowsPerPage))
+
+	if r.Page != 0 {
+		h.Set("X-Page", strconv.Itoa(r.Page))
+	}
+	if r.NextCursor != "" {
+		h.Set("X-Next-Cursor", r.NextCursor)
+	}
+	if r.PrevCursor != "" {
+		h.Set("X-Prev-Cursor", r.PrevCursor)
+	}
+}
+
 // =============================================================================
 
 // Page represents the requested page and rows per page.
 type Page struct {
 	Number      int
 	RowsPerPage int
+
+	// Cursor holds the raw cursor from the request when keyset paging is
+	// requested. An empty cursor asks for the first page.
+	Cursor    string
+	UseCursor bool
 }
 
 // ParseRequest parses the request for the page and rows query string. The defaults are provides as well.
+// Offset paging is the default, keyset paging is used when a cursor query parameter is provided.
 func ParseRequest(r *http.Request) (Page, error) {
 	values := r.URL.Query()
 	number := 1
@@ -57,8 +108,103 @@ func ParseRequest(r *http.Request) (Page, error) {
 		}
 	}
 
+	useCursor := values.Has("cursor")
+	if useCursor && values.Has("page") {
+		return Page{}, validate.NewFieldsError("cursor", errors.New("cursor can't be used together with page"))
+	}
+
 	return Page{
 		Number:      number,
 		RowsPerPage: rowsPerPage,
+		Cursor:      values.Get("cursor"),
+		UseCursor:   useCursor,
+	}, nil
+}
+
+// =============================================================================
+
+// MinCursorKeySize is the minimum size in bytes of the key signing cursors.
+const MinCursorKeySize = 32
+
+// Cursors encodes and decodes the opaque cursors handed out to clients. The
+// cursors are signed so clients can't forge a position in the result set.
+type Cursors struct {
+	key []byte
+}
+
+// NewCursors constructs a Cursors value that signs with the specified key,
+// which must hold at least MinCursorKeySize bytes.
+func NewCursors(key []byte) (*Cursors, error) {
+	if len(key) < MinCursorKeySize {
+		return nil, fmt.Errorf("cursor key must be at least %d bytes, got %d", MinCursorKeySize, len(key))
+	}
+
+	return &Cursors{
+		key: key,
 	}, nil
 }
+
+// Encode returns the signed, opaque form of the cursor.
+func (c *Cursors) Encode(cursor keyset.Cursor) string {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode verifies the signature of the cursor and returns its value. An empty
+// string decodes to a cursor at the start of the result set. A cursor that
+// can't be decoded or whose signature doesn't match returns a field error
+// holding keyset.ErrInvalidCursor.
+func (c *Cursors) Decode(cursor string) (keyset.Cursor, error) {
+	if cursor == "" {
+		return keyset.Cursor{Direction: keyset.Next}, nil
+	}
+
+	payload, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return keyset.Cursor{}, validate.NewFieldsError("cursor", keyset.ErrInvalidCursor)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return keyset.Cursor{}, validate.NewFieldsError("cursor", keyset.ErrInvalidCursor)
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.sign(data)) {
+		return keyset.Cursor{}, validate.NewFieldsError("cursor", keyset.ErrInvalidCursor)
+	}
+
+	var cur keyset.Cursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return keyset.Cursor{}, validate.NewFieldsError("cursor", keyset.ErrInvalidCursor)
+	}
+
+	if cur.Direction != keyset.Next && cur.Direction != keyset.Prev {
+		return keyset.Cursor{}, validate.NewFieldsError("cursor", keyset.ErrInvalidCursor)
+	}
+
+	return cur, nil
+}
+
+// EncodePage returns the next and previous cursors for a page of results.
+func EncodePage[T any](c *Cursors, page keyset.Page[T]) (next string, prev string) {
+	if cur, ok := page.NextCursor(); ok {
+		next = c.Encode(cur)
+	}
+
+	if cur, ok := page.PrevCursor(); ok {
+		prev = c.Encode(cur)
+	}
+
+	return next, prev
+}
+
+func (c *Cursors) sign(data []byte) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package paging_test
+
+import (
+	"encoding/base64"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/data/keyset"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/sys/validate"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/business/web/v1/paging"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const key = "0123456789abcdef0123456789abcdef"
+
+func Test_Cursors(t *testing.T) {
+	cursors, err := paging.NewCursors([]byte(key))
+	if err != nil {
+		t.Fatalf("Should be able to construct the cursors : %s", err)
+	}
+
+	cur := keyset.Cursor{
+		Direction: keyset.Prev,
+		Key:       []string{"bill@ardanlabs.com", "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"},
+		Order:     []string{"email ASC", "user_id ASC"},
+		Filter:    "9f86d081884c7d659a2feaa0c55ad015",
+	}
+
+	encoded := cursors.Encode(cur)
+
+	got, err := cursors.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Should be able to decode the cursor : %s", err)
+	}
+	if !reflect.DeepEqual(got, cur) {
+		t.Fatalf("Should get back the same cursor : exp %+v got %+v", cur, got)
+	}
+
+	start, err := cursors.Decode("")
+	if err != nil || !start.IsStart() || start.IsPrev() {
+		t.Fatalf("Should decode an empty cursor to the start : %+v %v", start, err)
+	}
+
+	payload, sig, _ := strings.Cut(encoded, ".")
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"d":"prev","k":["a","b"]}`))
+
+	other, err := paging.NewCursors([]byte(strings.Repeat("k", paging.MinCursorKeySize)))
+	if err != nil {
+		t.Fatalf("Should be able to construct the cursors : %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"tampered payload", forged + "." + sig},
+		{"tampered signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("signature"))},
+		{"missing signature", payload},
+		{"not base64", "%%%." + sig},
+		{"wrong key", other.Encode(cur)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cursors.Decode(tt.cursor)
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 || fe[0].Field != "cursor" || fe[0].Err != keyset.ErrInvalidCursor.Error() {
+				t.Fatalf("Should reject the cursor with a field error : %v", err)
+			}
+		})
+	}
+}
+
+func Test_NewCursorsKey(t *testing.T) {
+	for _, k := range []string{"", "change-me"} {
+		if _, err := paging.NewCursors([]byte(k)); err == nil {
+			t.Fatalf("Should reject a key of %d bytes", len(k))
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package alert turns the error records of the logger into alerts for the
+// people on call, without running a log pipeline.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Alert is what is sent to the sinks.
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Build   string    `json:"build"`
+	Message string    `json:"message"`
+	Caller  string    `json:"caller"`
+	TraceID string    `json:"trace_id,omitempty"`
+
+	// Count is the number of times the alert happened since it was last
+	// sent, including this one.
+	Count int `json:"count"`
+
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Sink delivers alerts, like to a webhook or a file.
+type Sink interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// Config represents the settings of a Dispatcher.
+type Config struct {
+	Service string
+	Build   string
+
+	// DedupeWindow is how long an alert with the error and caller of one
+	// just sent is counted instead of sent. The alerts counted are sent once
+	// the window passed, even when the error doesn't happen again. It
+	// defaults to 5m.
+	DedupeWindow time.Duration
+
+	// Limit bounds the number of alerts sent, whatever their message. It
+	// defaults to 10/1m.
+	Limit ratelimit.Limit
+
+	// QueueSize is the number of alerts waiting to be sent, alerts raised
+	// while the queue is full are dropped. It defaults to 100.
+	QueueSize int
+
+	// Timeout bounds sending an alert to a sink. It defaults to 10s.
+	Timeout time.Duration
+
+	// ErrorHandler is called when a sink fails. It must not log at the error
+	// level, or a failing sink would raise alerts about itself.
+	ErrorHandler func(err error)
+}
+
+// entry is the state of the alerts with the same error and caller.
+type entry struct {
+	last       Alert
+	sent       time.Time
+	suppressed int
+}
+
+// Dispatcher receives the error records of the logger through its Event
+// method, and sends them to the sinks in the background.
+type Dispatcher struct {
+	cfg     Config
+	sinks   []Sink
+	limiter *ratelimit.Memory
+
+	mu      sync.Mutex
+	closed  bool
+	entries map[string]*entry
+	alerts  chan Alert
+	done    chan struct{}
+}
+
+// New constructs a Dispatcher sending the alerts to the sinks.
+func New(cfg Config, sinks ...Sink) *Dispatcher {
+	if cfg.DedupeWindow <= 0 {
+		cfg.DedupeWindow = 5 * time.Minute
+	}
+	if cfg.Limit.Requests <= 0 || cfg.Limit.Period <= 0 {
+		cfg.Limit = ratelimit.Limit{Requests: 10, Period: time.Minute}
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(error) {}
+	}
+
+	d := Dispatcher{
+		cfg:     cfg,
+		sinks:   sinks,
+		limiter: ratelimit.NewMemory(),
+		entries: make(map[string]*entry),
+		alerts:  make(chan Alert, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+
+	go d.run()
+	go d.flushLoop()
+
+	return &d
+}
+
+// Event raises an alert for the record, unless the same alert was sent within
+// the dedupe window or too many alerts were sent lately. Those are counted in
+// the next alert sent for the record. It never blocks, so it can be used as a
+// logger.EventFunc.
+func (d *Dispatcher) Event(ctx context.Context, r logger.Record) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	alert := Alert{
+		Time:       now,
+		Service:    d.cfg.Service,
+		Build:      d.cfg.Build,
+		Message:    r.Message,
+		Caller:     r.Caller,
+		Attributes: make(map[string]any, len(r.Attributes)),
+	}
+
+	for k, v := range r.Attributes {
+		switch k {
+		case "trace_id":
+			alert.TraceID = fmt.Sprint(v)
+
+		default:
+			// Errors don't encode to JSON, their text is what matters.
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			alert.Attributes[k] = v
+		}
+	}
+
+	key := dedupeKey(r)
+
+	e, exists := d.entries[key]
+	if !exists {
+		e = &entry{}
+		d.entries[key] = e
+	}
+
+	// The last alert is what the suppressed ones are flushed as.
+	e.last = alert
+
+	if !e.sent.IsZero() && now.Sub(e.sent) < d.cfg.DedupeWindow {
+		e.suppressed++
+		return
+	}
+
+	if res, err := d.limiter.Take(ctx, "alerts", d.cfg.Limit, now); err != nil || !res.Allowed {
+		e.suppressed++
+		return
+	}
+
+	alert.Count = e.suppressed + 1
+
+	select {
+	case d.alerts <- alert:
+		e.sent = now
+		e.suppressed = 0
+
+	default:
+		e.suppressed++
+	}
+}
+
+// stackTrace marks the start of the stack trace within the text of an error,
+// like the one of a recovered panic.
+const stackTrace = " TRACE["
+
+// dedupeKey identifies the alerts of the record. The error logged is what
+// makes alerts the same, since the errors of every request are logged by the
+// same caller. Records without an error fall back to their message. A stack
+// trace is left out since it differs every time, even for the same panic.
+func dedupeKey(r logger.Record) string {
+	text := r.Message
+	if v, exists := r.Attributes["message"]; exists {
+		text = fmt.Sprint(v)
+	}
+	text, _, _ = strings.Cut(text, stackTrace)
+
+	return r.Caller + "|" + text
+}
+
+// flushLoop sends the alerts counted within the dedupe window once it passed,
+// so they aren't held until the error happens again.
+func (d *Dispatcher) flushLoop() {
+	ticker := time.NewTicker(d.cfg.DedupeWindow)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			d.flush(now)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// flush sends the alerts counted for the entries whose dedupe window passed,
+// and forgets the entries without any.
+func (d *Dispatcher) flush(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	for key, e := range d.entries {
+		if now.Sub(e.sent) < d.cfg.DedupeWindow {
+			continue
+		}
+
+		if e.suppressed == 0 {
+			delete(d.entries, key)
+			continue
+		}
+
+		if res, err := d.limiter.Take(context.Background(), "alerts", d.cfg.Limit, now); err != nil || !res.Allowed {
+			continue
+		}
+
+		alert := e.last
+		alert.Time = now
+		alert.Count = e.suppressed
+
+		select {
+		case d.alerts <- alert:
+			e.sent = now
+			e.suppressed = 0
+
+		default:
+		}
+	}
+}
+
+// Shutdown sends the alerts still queued. Alerts raised after Shutdown are
+// dropped.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.alerts)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("alert shutdown: %w", ctx.Err())
+	}
+}
+
+// run sends the queued alerts to every sink until the dispatcher is shut
+// down.
+func (d *Dispatcher) run() {
+	defer close(d.done)
+
+	for alert := range d.alerts {
+		for _, sink := range d.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+
+			if err := sink.Send(ctx, alert); err != nil {
+				d.cfg.ErrorHandler(fmt.Errorf("sending alert %q: %w", alert.Message, err))
+			}
+
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package alert_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/alert"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Dispatcher(t *testing.T) {
+	var mu sync.Mutex
+	var received []alert.Alert
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert.Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("Should be able to decode the alert : %s", err)
+		}
+
+		mu.Lock()
+		received = append(received, a)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "alerts.log")
+	file, err := alert.NewFile(path)
+	if err != nil {
+		t.Fatalf("Should be able to open the alert file : %s", err)
+	}
+	defer file.Close()
+
+	d := alert.New(alert.Config{
+		Service:      "SALES-API",
+		Build:        "v1.2.3",
+		DedupeWindow: time.Minute,
+		Limit:        ratelimit.Limit{Requests: 2, Period: time.Hour},
+		ErrorHandler: func(err error) {
+			t.Errorf("Should be able to send the alert : %s", err)
+		},
+	}, alert.NewWebhook(srv.URL), file)
+
+	ctx := context.Background()
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	record := func(msg string, caller string, err string, at time.Duration) logger.Record {
+		return logger.Record{
+			Time:    now.Add(at),
+			Message: msg,
+			Level:   logger.LevelError,
+			Caller:  caller,
+			Attributes: map[string]any{
+				"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+				"message":  errors.New(err),
+			},
+		}
+	}
+
+	// Sent, then counted twice within the dedupe window.
+	d.Event(ctx, record("ERROR", "errors.go:24", "db down", 0))
+	d.Event(ctx, record("ERROR", "errors.go:24", "db down", time.Second))
+	d.Event(ctx, record("ERROR", "errors.go:24", "db down", 2*time.Second))
+
+	// Another error is another alert, then the rate limit is reached.
+	d.Event(ctx, record("PANIC", "errors.go:24", "nil map", 3*time.Second))
+	d.Event(ctx, record("ERROR", "userdb.go:80", "db down", 4*time.Second))
+
+	// Once the window passed, the alert is rate limited.
+	d.Event(ctx, record("ERROR", "errors.go:24", "db down", 2*time.Minute))
+
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Should send 2 alerts : got %d : %+v", len(received), received)
+	}
+
+	exp := []struct {
+		msg   string
+		err   string
+		count int
+	}{
+		{"ERROR", "db down", 1},
+		{"PANIC", "nil map", 1},
+	}
+
+	for i, a := range received {
+		if a.Message != exp[i].msg || a.Count != exp[i].count {
+			t.Fatalf("Should send %s with a count of %d : got %s with %d", exp[i].msg, exp[i].count, a.Message, a.Count)
+		}
+		if a.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || a.Build != "v1.2.3" || a.Service != "SALES-API" {
+			t.Fatalf("Should include the trace id, build and service : %+v", a)
+		}
+		if a.Attributes["message"] != exp[i].err {
+			t.Fatalf("Should include the attributes of the record : %+v", a.Attributes)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Should be able to read the alert file : %s", err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var a alert.Alert
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			t.Fatalf("Should write one alert per line : %s", err)
+		}
+		lines++
+	}
+
+	if lines != 2 {
+		t.Fatalf("Should write 2 alerts to the file : got %d", lines)
+	}
+}
+
+func Test_DispatcherCount(t *testing.T) {
+	var received []alert.Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert.Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		received = append(received, a)
+	}))
+	defer srv.Close()
+
+	d := alert.New(alert.Config{DedupeWindow: time.Minute}, alert.NewWebhook(srv.URL))
+
+	ctx := context.Background()
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		d.Event(ctx, logger.Record{Time: now.Add(time.Duration(i) * time.Second), Message: "ERROR", Caller: "errors.go:24"})
+	}
+	d.Event(ctx, logger.Record{Time: now.Add(2 * time.Minute), Message: "ERROR", Caller: "errors.go:24"})
+
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	if len(received) != 2 || received[0].Count != 1 || received[1].Count != 5 {
+		t.Fatalf("Should count the suppressed alerts in the next one : got %+v", received)
+	}
+}
+
+func Test_DispatcherFlush(t *testing.T) {
+	var mu sync.Mutex
+	var received []alert.Alert
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert.Alert
+		json.NewDecoder(r.Body).Decode(&a)
+
+		mu.Lock()
+		received = append(received, a)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	d := alert.New(alert.Config{DedupeWindow: 50 * time.Millisecond}, alert.NewWebhook(srv.URL))
+
+	ctx := context.Background()
+	record := logger.Record{
+		Message:    "ERROR",
+		Caller:     "errors.go:24",
+		Attributes: map[string]any{"message": errors.New("db down")},
+	}
+
+	for i := 0; i < 4; i++ {
+		d.Event(ctx, record)
+	}
+
+	// The suppressed alerts are sent without the error happening again.
+	time.Sleep(300 * time.Millisecond)
+
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 2 || received[0].Count != 1 || received[1].Count != 3 {
+		t.Fatalf("Should flush the suppressed alerts once the window passed : got %+v", received)
+	}
+	if received[1].Attributes["message"] != "db down" {
+		t.Fatalf("Should flush the suppressed alerts with their error : %+v", received[1])
+	}
+}
+
+func Test_DispatcherPanic(t *testing.T) {
+	var received []alert.Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a alert.Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		received = append(received, a)
+	}))
+	defer srv.Close()
+
+	d := alert.New(alert.Config{DedupeWindow: time.Minute}, alert.NewWebhook(srv.URL))
+
+	ctx := context.Background()
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// The stack of every panic differs, by goroutine id at least.
+	for i, stack := range []string{"goroutine 7 [running]", "goroutine 9 [running]", "goroutine 12 [running]"} {
+		d.Event(ctx, logger.Record{
+			Time:       now.Add(time.Duration(i) * time.Second),
+			Message:    "PANIC",
+			Caller:     "errors.go:24",
+			Attributes: map[string]any{"message": errors.New("PANIC [nil map] TRACE[" + stack + "]")},
+		})
+	}
+	d.Event(ctx, logger.Record{Time: now.Add(2 * time.Minute), Message: "PANIC", Caller: "errors.go:24", Attributes: map[string]any{"message": errors.New("PANIC [nil map] TRACE[goroutine 20 [running]]")}})
+
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	if len(received) != 2 || received[0].Count != 1 || received[1].Count != 3 {
+		t.Fatalf("Should dedupe the panics whatever their stack : got %+v", received)
+	}
+}
+
+func Test_WebhookErrorURL(t *testing.T) {
+	const secret = "T000/B000/secret-token"
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"unreachable", "http://127.0.0.1:1/services/" + secret},
+		{"invalid", "http://bad host/services/" + secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := alert.NewWebhook(tt.url).Send(context.Background(), alert.Alert{Message: "ERROR"})
+			if err == nil {
+				t.Fatalf("Should fail to send the alert.")
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("Should NOT include the url of the webhook in the error : %s", err)
+			}
+		})
+	}
+}
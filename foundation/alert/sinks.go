@@ -0,0 +1,110 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Webhook posts alerts as JSON to a url, like the incoming webhook of a chat
+// or paging service.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook constructs a sink posting alerts to the url.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{},
+	}
+}
+
+// Send posts the alert to the webhook.
+func (w *Webhook) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("encoding alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting alert: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook responded with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// withoutURL removes the url from the error, since the url of a webhook holds
+// its secret and the error ends up in the logs.
+func withoutURL(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return fmt.Errorf("%s webhook: %w", uerr.Op, uerr.Err)
+	}
+
+	return err
+}
+
+// =============================================================================
+
+// File appends alerts to a file, one JSON document per line.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile constructs a sink appending alerts to the file at the path, which
+// is created when it doesn't exist.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening alert file: %w", err)
+	}
+
+	return &File{f: f}, nil
+}
+
+// Send appends the alert to the file.
+func (f *File) Send(ctx context.Context, alert Alert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("encoding alert: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing alert: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,97 @@
+// Package graceful coordinates the graceful shutdown of a service, so the
+// load balancer stops sending traffic before the servers stop taking it.
+package graceful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"net/http"
+	"time"
+)
+
+// Config represents the settings of a Coordinator.
+type Config struct {
+	Log *logger.Logger
+
+	// DrainDelay is how long the service keeps serving after it stopped
+	// being ready, giving the load balancer time to notice.
+	DrainDelay time.Duration
+
+	// Timeout bounds the shutdown of the servers, once the drain delay
+	// passed.
+	Timeout time.Duration
+}
+
+type server struct {
+	name string
+	srv  *http.Server
+}
+
+// Coordinator shuts the service down in phases. It first runs the drain
+// functions, which fail readiness, then waits the drain delay and finally
+// shuts down the servers in the order they were added.
+type Coordinator struct {
+	cfg     Config
+	drain   []func()
+	servers []server
+}
+
+// New constructs a Coordinator.
+func New(cfg Config) *Coordinator {
+	return &Coordinator{
+		cfg: cfg,
+	}
+}
+
+// OnDrain adds a function run when the shutdown starts, like failing the
+// readiness of the service.
+func (c *Coordinator) OnDrain(f func()) {
+	c.drain = append(c.drain, f)
+}
+
+// AddServer adds a server to shut down once the drain delay passed.
+func (c *Coordinator) AddServer(name string, srv *http.Server) {
+	c.servers = append(c.servers, server{name: name, srv: srv})
+}
+
+// Shutdown runs the phases of the shutdown. A server that doesn't stop
+// within the timeout is closed, its in flight requests are dropped. The
+// drain delay is cut short when the context is canceled.
+func (c *Coordinator) Shutdown(ctx context.Context) error {
+	c.cfg.Log.Info(ctx, "shutdown", "status", "draining", "delay", c.cfg.DrainDelay.String())
+
+	for _, f := range c.drain {
+		f()
+	}
+
+	if c.cfg.DrainDelay > 0 {
+		timer := time.NewTimer(c.cfg.DrainDelay)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			c.cfg.Log.Info(ctx, "shutdown", "status", "drain delay cut short")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cfg.Timeout)
+	defer cancel()
+
+	var errs []error
+	for _, s := range c.servers {
+		c.cfg.Log.Info(ctx, "shutdown", "status", "stopping server", "server", s.name, "host", s.srv.Addr)
+
+		if err := s.srv.Shutdown(ctx); err != nil {
+			s.srv.Close()
+			errs = append(errs, fmt.Errorf("could not stop %s server gracefully: %w", s.name, err))
+			continue
+		}
+
+		c.cfg.Log.Info(ctx, "shutdown", "status", "server stopped", "server", s.name)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package graceful_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/graceful"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Shutdown(t *testing.T) {
+	var draining atomic.Bool
+
+	// The server keeps answering while the coordinator waits the drain delay.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Should be able to listen : %s", err)
+	}
+
+	srv := http.Server{Handler: handler}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+
+	coordinator := graceful.New(graceful.Config{
+		Log:        logger.New(logger.Config{Writer: io.Discard}),
+		DrainDelay: 200 * time.Millisecond,
+		Timeout:    time.Second,
+	})
+	coordinator.OnDrain(func() { draining.Store(true) })
+	coordinator.AddServer("api", &srv)
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- coordinator.Shutdown(context.Background())
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Should keep serving during the drain delay : %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Should run the drain functions first : got %d", resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("Should wait the drain delay : took %s", d)
+	}
+
+	if err := <-served; err != http.ErrServerClosed {
+		t.Fatalf("Should stop the server : got %v", err)
+	}
+}
+
+func Test_ShutdownCanceled(t *testing.T) {
+	coordinator := graceful.New(graceful.Config{
+		Log:        logger.New(logger.Config{Writer: io.Discard}),
+		DrainDelay: time.Minute,
+		Timeout:    time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := coordinator.Shutdown(ctx); err != nil {
+		t.Fatalf("Should be able to shut down : %s", err)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Should cut the drain delay short : took %s", d)
+	}
+}
@@ -4,6 +4,7 @@ package keystore
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -111,6 +112,18 @@ func (ks *KeyStore) PrivateKey(kid string) (string, error) {
 	return string(privateKey.PEM), nil
 }
 
+// Checker returns a health check that fails when the key store doesn't hold
+// the active key, for the readiness checks.
+func (ks *KeyStore) Checker(activeKID string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if _, found := ks.store[activeKID]; !found {
+			return fmt.Errorf("active kid %q not found", activeKID)
+		}
+
+		return nil
+	}
+}
+
 // PublicKey searches the key store for a given kid and returns the public key.
 func (ks *KeyStore) PublicKey(kid string) (string, error) {
 	privateKey, found := ks.store[kid]
@@ -11,32 +11,39 @@ import (
 type logHandler struct {
 	handler slog.Handler
 	events  Events
+	debugFn DebugFn
 }
 
-func newLogHandler(handler slog.Handler, events Events) *logHandler {
+func newLogHandler(handler slog.Handler, events Events, debugFn DebugFn) *logHandler {
 	return &logHandler{
 		handler: handler,
 		events:  events,
+		debugFn: debugFn,
 	}
 }
 
 // Enabled reports whether the handler handles records at the given level.
-// The handler ignores records whose level is lower.
+// The handler ignores records whose level is lower, unless the context asked
+// for debug logging.
 func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.debugFn != nil && h.debugFn(ctx) {
+		return true
+	}
+
 	return h.handler.Enabled(ctx, level)
 }
 
 // WithAttrs returns a new JSONHandler whose attributes consists
 // of h's attributes followed by attrs.
 func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &logHandler{handler: h.handler.WithAttrs(attrs), events: h.events}
+	return &logHandler{handler: h.handler.WithAttrs(attrs), events: h.events, debugFn: h.debugFn}
 }
 
 // WithGroup returns a new Handler with the given group appended to the receiver's
 // existing groups. The keys of all subsequent attributes, whether added by With
 // or in a Record, should be qualified by the sequence of group names.
 func (h *logHandler) WithGroup(name string) slog.Handler {
-	return &logHandler{handler: h.handler.WithGroup(name), events: h.events}
+	return &logHandler{handler: h.handler.WithGroup(name), events: h.events, debugFn: h.debugFn}
 }
 
 // Handle looks to see if an event function needs to be executed for a given
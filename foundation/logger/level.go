@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// ParseLevel parses a level name like debug or INFO.
+func ParseLevel(s string) (Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("parsing level %q: %w", s, err)
+	}
+
+	return Level(level), nil
+}
+
+func (l Level) String() string {
+	return slog.Level(l).String()
+}
+
+// LevelHandler returns a handler reporting the level of the logger on GET and
+// changing it on PUT, both using {"level":"debug"}.
+func (log *Logger) LevelHandler() http.Handler {
+	type payload struct {
+		Level string `json:"level"`
+	}
+
+	f := func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+
+		case http.MethodPut:
+			var p payload
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				http.Error(w, fmt.Sprintf("decoding payload: %s", err), http.StatusBadRequest)
+				return
+			}
+
+			level, err := ParseLevel(p.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			log.SetLevel(level)
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payload{Level: log.Level().String()})
+	}
+
+	return http.HandlerFunc(f)
+}
@@ -1,32 +1,151 @@
-// Package logger provides a convenience function to constructing a logger
-// for use. This is required not just for applications but for testing.
+// Package logger provides support for initializing the log system. This is
+// required not just for applications but for testing.
 package logger
 
 import (
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
 )
 
-// New constructs a Sugared Logger that writes to stdout and
-// provides human-readable timestamps.
-func New(service string, outputPaths ...string) (*zap.SugaredLogger, error) {
-	config := zap.NewProductionConfig()
+// TraceIDFn returns the trace id held by the context, or an empty string.
+type TraceIDFn func(ctx context.Context) string
 
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	config.DisableStacktrace = true
-	config.InitialFields = map[string]any{
-		"service": service,
+// DebugFn reports whether the work of the context asked to be logged at the
+// debug level, no matter the level of the logger.
+type DebugFn func(ctx context.Context) bool
+
+// Config represents the settings of a Logger.
+type Config struct {
+	// Writer defaults to stdout.
+	Writer io.Writer
+
+	// Level is the lowest level logged, it can be changed later with
+	// SetLevel. It defaults to info.
+	Level Level
+
+	Service   string
+	TraceIDFn TraceIDFn
+	DebugFn   DebugFn
+
+	// Events are run for the records logged at their level.
+	Events Events
+}
+
+// Logger represents a logger for logging information.
+type Logger struct {
+	handler   slog.Handler
+	level     *slog.LevelVar
+	traceIDFn TraceIDFn
+}
+
+// New constructs a Logger writing JSON records with the service name, the
+// caller and, when the context holds one, the trace id.
+func New(cfg Config) *Logger {
+	if cfg.Writer == nil {
+		cfg.Writer = os.Stdout
 	}
 
-	config.OutputPaths = []string{"stdout"}
-	if outputPaths != nil {
-		config.OutputPaths = outputPaths
+	level := new(slog.LevelVar)
+	level.Set(slog.Level(cfg.Level))
+
+	// Only keep the file name and line of the caller.
+	replace := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.SourceKey {
+			if source, ok := a.Value.Any().(*slog.Source); ok {
+				v := fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line)
+				return slog.Attr{Key: "file", Value: slog.StringValue(v)}
+			}
+		}
+
+		return a
+	}
+
+	var handler slog.Handler = slog.NewJSONHandler(cfg.Writer, &slog.HandlerOptions{AddSource: true, Level: level, ReplaceAttr: replace})
+	handler = handler.WithAttrs([]slog.Attr{slog.String("service", cfg.Service)})
+
+	return &Logger{
+		handler:   newLogHandler(handler, cfg.Events, cfg.DebugFn),
+		level:     level,
+		traceIDFn: cfg.TraceIDFn,
+	}
+}
+
+// NewStdLogger returns a standard library logger writing to the logger at
+// the level, for the packages that need one like http.Server.
+func NewStdLogger(logger *Logger, level Level) *log.Logger {
+	return slog.NewLogLogger(logger.handler, slog.Level(level))
+}
+
+// Level returns the lowest level logged.
+func (log *Logger) Level() Level {
+	return Level(log.level.Level())
+}
+
+// SetLevel changes the lowest level logged.
+func (log *Logger) SetLevel(level Level) {
+	log.level.Set(slog.Level(level))
+}
+
+// With returns a logger adding the key value pairs to every record.
+func (log *Logger) With(args ...any) *Logger {
+	l := *log
+	l.handler = slog.New(log.handler).With(args...).Handler()
+
+	return &l
+}
+
+// Debug logs at LevelDebug with the given context.
+func (log *Logger) Debug(ctx context.Context, msg string, args ...any) {
+	log.write(ctx, LevelDebug, 3, msg, args...)
+}
+
+// Info logs at LevelInfo with the given context.
+func (log *Logger) Info(ctx context.Context, msg string, args ...any) {
+	log.write(ctx, LevelInfo, 3, msg, args...)
+}
+
+// Infoc logs at LevelInfo like Info, reporting the caller skip frames above
+// the one calling Infoc. It's used by helpers logging on behalf of their
+// callers.
+func (log *Logger) Infoc(ctx context.Context, skip int, msg string, args ...any) {
+	log.write(ctx, LevelInfo, 3+skip, msg, args...)
+}
+
+// Warn logs at LevelWarn with the given context.
+func (log *Logger) Warn(ctx context.Context, msg string, args ...any) {
+	log.write(ctx, LevelWarn, 3, msg, args...)
+}
+
+// Error logs at LevelError with the given context.
+func (log *Logger) Error(ctx context.Context, msg string, args ...any) {
+	log.write(ctx, LevelError, 3, msg, args...)
+}
+
+func (log *Logger) write(ctx context.Context, level Level, caller int, msg string, args ...any) {
+	slogLevel := slog.Level(level)
+
+	if !log.handler.Enabled(ctx, slogLevel) {
+		return
 	}
 
-	log, err := config.Build(zap.WithCaller(true))
-	if err != nil {
-		return nil, err
+	var pcs [1]uintptr
+	runtime.Callers(caller, pcs[:])
+
+	r := slog.NewRecord(time.Now(), slogLevel, msg, pcs[0])
+
+	if log.traceIDFn != nil {
+		if traceID := log.traceIDFn(ctx); traceID != "" {
+			args = append(args, "trace_id", traceID)
+		}
 	}
+	r.Add(args...)
 
-	return log.Sugar(), nil
+	log.handler.Handle(ctx, r)
 }
@@ -0,0 +1,116 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type ctxKey int
+
+const (
+	traceKey ctxKey = iota + 1
+	debugKey
+)
+
+func Test_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	var errors []logger.Record
+
+	log := logger.New(logger.Config{
+		Writer:  &buf,
+		Service: "TEST",
+		TraceIDFn: func(ctx context.Context) string {
+			id, _ := ctx.Value(traceKey).(string)
+			return id
+		},
+		DebugFn: func(ctx context.Context) bool {
+			return ctx.Value(debugKey) != nil
+		},
+		Events: logger.Events{
+			Error: func(ctx context.Context, r logger.Record) {
+				errors = append(errors, r)
+			},
+		},
+	})
+	log = log.With("user", "bill")
+
+	ctx := context.WithValue(context.Background(), traceKey, "4bf92f3577b34da6a3ce929d0e0e4736")
+	debugCtx := context.WithValue(ctx, debugKey, true)
+
+	log.Debug(ctx, "hidden")
+	log.Debug(debugCtx, "requested")
+
+	log.SetLevel(logger.LevelDebug)
+	log.Debug(ctx, "raised")
+
+	log.SetLevel(logger.LevelError)
+	log.Info(ctx, "lowered")
+	log.Error(ctx, "failed", "ERROR", "boom")
+
+	type entry struct {
+		Msg     string `json:"msg"`
+		Service string `json:"service"`
+		User    string `json:"user"`
+		TraceID string `json:"trace_id"`
+		File    string `json:"file"`
+	}
+
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Should be able to decode the entry : %s", err)
+		}
+		if e.Service != "TEST" || e.User != "bill" {
+			t.Fatalf("Should keep the fields of the logger : %s", line)
+		}
+		if e.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("Should add the trace id of the context : %s", line)
+		}
+		if !strings.HasPrefix(e.File, "logger_test.go:") {
+			t.Fatalf("Should report the caller : %s", line)
+		}
+		msgs = append(msgs, e.Msg)
+	}
+
+	exp := []string{"requested", "raised", "failed"}
+	if strings.Join(msgs, ",") != strings.Join(exp, ",") {
+		t.Fatalf("Should log %v : got %v", exp, msgs)
+	}
+
+	if len(errors) != 1 || errors[0].Message != "failed" || errors[0].Attributes["ERROR"] != "boom" {
+		t.Fatalf("Should run the error event : got %+v", errors)
+	}
+}
+
+func Test_LevelHandler(t *testing.T) {
+	log := logger.New(logger.Config{Writer: &bytes.Buffer{}})
+	h := log.LevelHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/loglevel", strings.NewReader(`{"level":"debug"}`)))
+
+	if w.Code != http.StatusOK || log.Level() != logger.LevelDebug {
+		t.Fatalf("Should change the level : got %d, %s", w.Code, log.Level())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/loglevel", strings.NewReader(`{"level":"loud"}`)))
+
+	if w.Code != http.StatusBadRequest || log.Level() != logger.LevelDebug {
+		t.Fatalf("Should reject an unknown level : got %d, %s", w.Code, log.Level())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/loglevel", nil))
+
+	if got := strings.TrimSpace(w.Body.String()); got != `{"level":"DEBUG"}` {
+		t.Fatalf("Should report the level : got %s", got)
+	}
+}
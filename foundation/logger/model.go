@@ -2,6 +2,9 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"log/slog"
@@ -22,9 +25,14 @@ const (
 
 // Record represents the data that is being logged.
 type Record struct {
-	Time       time.Time
-	Message    string
-	Level      Level
+	Time    time.Time
+	Message string
+	Level   Level
+
+	// Caller is the file and line the record was logged from, like
+	// errors.go:24.
+	Caller string
+
 	Attributes map[string]any
 }
 
@@ -37,10 +45,17 @@ func toRecord(r slog.Record) Record {
 	}
 	r.Attrs(f)
 
+	var caller string
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		caller = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+
 	return Record{
 		Time:       r.Time,
 		Message:    r.Message,
 		Level:      Level(r.Level),
+		Caller:     caller,
 		Attributes: atts,
 	}
 }
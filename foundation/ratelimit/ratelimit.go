@@ -0,0 +1,157 @@
+// Package ratelimit provides token bucket rate limiting with pluggable
+// storage for the buckets.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket. Requests tokens are added to the bucket
+// every Period and the bucket holds at most Burst tokens. Burst defaults to
+// Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseLimit parses a limit in the form of "100/1m", optionally followed by a
+// burst like "100/1m,20".
+func ParseLimit(s string) (Limit, error) {
+	s, burst, hasBurst := strings.Cut(s, ",")
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected requests/period", s)
+	}
+
+	var l Limit
+	var err error
+
+	l.Requests, err = strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil {
+		return Limit{}, fmt.Errorf("parsing requests: %w", err)
+	}
+
+	l.Period, err = time.ParseDuration(strings.TrimSpace(period))
+	if err != nil {
+		return Limit{}, fmt.Errorf("parsing period: %w", err)
+	}
+
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(strings.TrimSpace(burst))
+		if err != nil {
+			return Limit{}, fmt.Errorf("parsing burst: %w", err)
+		}
+	}
+
+	if err := l.validate(); err != nil {
+		return Limit{}, err
+	}
+
+	return l, nil
+}
+
+// String returns the limit in the form accepted by ParseLimit.
+func (l Limit) String() string {
+	s := strconv.Itoa(l.Requests) + "/" + l.Period.String()
+	if l.Burst != 0 {
+		s += "," + strconv.Itoa(l.Burst)
+	}
+
+	return s
+}
+
+// Capacity returns the number of tokens the bucket holds when full.
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+// rate returns the number of tokens added to the bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) validate() error {
+	if l.Requests <= 0 || l.Period <= 0 || l.Burst < 0 {
+		return errors.New("limit requires positive requests and period")
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// RetryAfter is how long until a token is available again. It's zero
+	// when the request was allowed.
+	RetryAfter time.Duration
+
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store is the behavior required to hold the buckets. The in-memory store
+// limits a single instance, a shared store lets every instance of the service
+// enforce the same limits.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is the state of a token bucket at a point in time.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time that passed and takes a token from it
+// when one is available.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Capacity())
+	rate := limit.rate()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.last = now
+	}
+
+	res := Result{
+		Limit: limit.Capacity(),
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = seconds((capacity - b.tokens) / rate)
+
+	return res
+}
+
+// full reports whether the bucket would be full at the specified time.
+func (b *bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.rate() >= float64(limit.Capacity())
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
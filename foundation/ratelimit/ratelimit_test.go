@@ -0,0 +1,79 @@
+package ratelimit_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/ratelimit"
+	"testing"
+	"time"
+)
+
+func Test_Memory(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemory()
+	limit := ratelimit.Limit{Requests: 2, Period: time.Second}
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		res, err := store.Take(ctx, "bill", limit, now)
+		if err != nil {
+			t.Fatalf("Should be able to take a token : %s", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("Should allow request %d of the burst", i+1)
+		}
+		if res.Remaining != 1-i {
+			t.Fatalf("Should have %d tokens remaining : got %d", 1-i, res.Remaining)
+		}
+	}
+
+	res, err := store.Take(ctx, "bill", limit, now)
+	if err != nil {
+		t.Fatalf("Should be able to take a token : %s", err)
+	}
+	if res.Allowed {
+		t.Fatalf("Should reject the request once the bucket is empty")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("Should retry after 500ms : got %v", res.RetryAfter)
+	}
+
+	res, err = store.Take(ctx, "ale", limit, now)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Should keep a separate bucket per key : %v %s", res.Allowed, err)
+	}
+
+	res, err = store.Take(ctx, "bill", limit, now.Add(res.RetryAfter+500*time.Millisecond))
+	if err != nil || !res.Allowed {
+		t.Fatalf("Should allow the request once the bucket refilled : %v %s", res.Allowed, err)
+	}
+
+	if _, err := store.Take(ctx, "jill", limit, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Should be able to take a token : %s", err)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("Should drop the buckets that refilled : got %d buckets", store.Len())
+	}
+}
+
+func Test_ParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ratelimit.Limit
+		err  bool
+	}{
+		{"100/1m", ratelimit.Limit{Requests: 100, Period: time.Minute}, false},
+		{"10/1s,20", ratelimit.Limit{Requests: 10, Period: time.Second, Burst: 20}, false},
+		{"100", ratelimit.Limit{}, true},
+		{"0/1m", ratelimit.Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ratelimit.ParseLimit(tt.in)
+		if (err != nil) != tt.err {
+			t.Fatalf("%s: Should get error %v : got %v", tt.in, tt.err, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: Should parse to %+v : got %+v", tt.in, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package trace records the spans of work done for a request and hands them to
+// an exporter, so a request can be followed across services.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace, the tree of spans of a request.
+type TraceID [16]byte
+
+// IsValid reports whether the trace id isn't all zeros.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the span id isn't all zeros.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// Flags are the trace flags carried along with the span context.
+type Flags byte
+
+// FlagSampled marks a trace whose spans are recorded.
+const FlagSampled Flags = 0x01
+
+// SpanContext is the part of a span that is propagated to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   Flags
+
+	// State is the vendor specific tracestate, passed along untouched.
+	State string
+
+	// Remote is set when the span context came from another service.
+	Remote bool
+}
+
+// IsValid reports whether both ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the spans of the trace are recorded.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// =============================================================================
+
+// SpanKind describes the relationship of a span to its parent. The values
+// match the ones of OTLP.
+type SpanKind int
+
+// Set of span kinds.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the outcome of a span. The values match the ones of OTLP.
+type StatusCode int
+
+// Set of status codes.
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a key and value describing a span.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr constructs an attribute.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is a snapshot of an ended span handed to the exporter.
+type SpanData struct {
+	Name          string
+	SpanContext   SpanContext
+	Parent        SpanID
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span represents a unit of work within a trace. A nil span, or one that
+// isn't sampled, doesn't record anything, so callers never have to check.
+type Span struct {
+	tracer    *Tracer
+	recording bool
+
+	mu   sync.Mutex
+	data SpanData
+	done bool
+}
+
+// SpanContext returns the span context of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.data.SpanContext
+}
+
+// IsRecording reports whether the span will be exported once ended.
+func (s *Span) IsRecording() bool {
+	return s != nil && s.recording
+}
+
+// SetKind sets the kind of the span.
+func (s *Span) SetKind(kind SpanKind) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Kind = kind
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetError marks the span as failed. A nil error is ignored.
+func (s *Span) SetError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End records the end of the span and queues it for export. Calls after the
+// first are ignored.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.queue(data)
+}
+
+// =============================================================================
+
+type ctxKey int
+
+const (
+	spanKey ctxKey = iota + 1
+	remoteKey
+)
+
+// ContextWithSpan returns a copy of the context holding the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the span held by the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a copy of the context holding the span
+// context received from another service. The next span started becomes its
+// child.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// Start starts a child of the span held by the context. Without a span in the
+// context there is no trace to add to, so a nil span is returned.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	return parent.tracer.start(ctx, name, parent.SpanContext(), SpanKindInternal, attrs)
+}
+
+// newSpanID returns a random span id.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
+
+// newTraceID returns a random trace id.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+
+	return id
+}
+
+// sampled decides whether a new trace is recorded from the low bits of its id,
+// so every service makes the same decision for a trace id.
+func sampled(id TraceID, probability float64) bool {
+	switch {
+	case probability >= 1:
+		return true
+	case probability <= 0:
+		return false
+	}
+
+	return float64(binary.BigEndian.Uint64(id[8:])>>11)/(1<<53) < probability
+}
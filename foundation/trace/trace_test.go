@@ -0,0 +1,175 @@
+package trace_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector stands in for an OpenTelemetry collector, keeping the requests it
+// receives.
+type collector struct {
+	mu       sync.Mutex
+	requests []map[string]any
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+
+	var req map[string]any
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+	c.mu.Unlock()
+
+	w.Write([]byte("{}"))
+}
+
+// spans returns the spans received, keyed by name.
+func (c *collector) spans() map[string]map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	spans := make(map[string]map[string]any)
+	for _, req := range c.requests {
+		for _, rs := range req["resourceSpans"].([]any) {
+			for _, ss := range rs.(map[string]any)["scopeSpans"].([]any) {
+				for _, span := range ss.(map[string]any)["spans"].([]any) {
+					span := span.(map[string]any)
+					spans[span["name"].(string)] = span
+				}
+			}
+		}
+	}
+
+	return spans
+}
+
+func Test_Tracer(t *testing.T) {
+	c := collector{}
+	srv := httptest.NewServer(&c)
+	defer srv.Close()
+
+	exporter := trace.NewOTLPExporter(trace.OTLPConfig{
+		Endpoint:       srv.URL + "/v1/traces",
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		ServiceName:    "sales-api",
+		ServiceVersion: "test",
+	})
+
+	tracer := trace.New(exporter, trace.Config{Probability: 1, BatchTimeout: time.Hour})
+
+	ctx, root := tracer.Start(context.Background(), "GET /users", trace.Attr("http.method", "GET"))
+	_, child := trace.Start(ctx, "database.NamedQuerySlice", trace.Attr("rows", 3))
+	child.SetKind(trace.SpanKindClient)
+	child.SetError(errors.New("query failed"))
+	child.End()
+	root.End()
+
+	// Ending twice must not export the span twice.
+	root.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Should be able to shut down the tracer : %s", err)
+	}
+
+	if len(c.requests) != 1 {
+		t.Fatalf("Should export the spans in one batch : got %d", len(c.requests))
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Fatalf("Should send the configured headers : got %q", got)
+	}
+
+	spans := c.spans()
+	if len(spans) != 2 {
+		t.Fatalf("Should export 2 spans : got %d", len(spans))
+	}
+
+	rootSpan := spans["GET /users"]
+	childSpan := spans["database.NamedQuerySlice"]
+
+	rootSC := root.SpanContext()
+	if rootSpan["traceId"] != rootSC.TraceID.String() || rootSpan["spanId"] != rootSC.SpanID.String() {
+		t.Fatalf("Should export the ids as hex : got %v %v", rootSpan["traceId"], rootSpan["spanId"])
+	}
+	if _, exists := rootSpan["parentSpanId"]; exists {
+		t.Fatalf("Should export the root span without a parent : got %v", rootSpan["parentSpanId"])
+	}
+	if rootSpan["kind"] != float64(trace.SpanKindServer) {
+		t.Fatalf("Should export the root span as a server span : got %v", rootSpan["kind"])
+	}
+
+	if childSpan["traceId"] != rootSC.TraceID.String() || childSpan["parentSpanId"] != rootSC.SpanID.String() {
+		t.Fatalf("Should export the child within the trace of its parent : got %v %v", childSpan["traceId"], childSpan["parentSpanId"])
+	}
+	if childSpan["kind"] != float64(trace.SpanKindClient) {
+		t.Fatalf("Should export the kind of the child : got %v", childSpan["kind"])
+	}
+	if status := childSpan["status"].(map[string]any); status["code"] != float64(trace.StatusError) || status["message"] != "query failed" {
+		t.Fatalf("Should export the error of the child : got %v", status)
+	}
+
+	attrs := childSpan["attributes"].([]any)
+	if attr := attrs[0].(map[string]any); attr["key"] != "rows" || attr["value"].(map[string]any)["intValue"] != "3" {
+		t.Fatalf("Should export integers as strings : got %v", attr)
+	}
+
+	resource := c.requests[0]["resourceSpans"].([]any)[0].(map[string]any)["resource"].(map[string]any)
+	if attr := resource["attributes"].([]any)[0].(map[string]any); attr["value"].(map[string]any)["stringValue"] != "sales-api" {
+		t.Fatalf("Should export the service name : got %v", attr)
+	}
+}
+
+func Test_Sampling(t *testing.T) {
+	exporter := trace.NewOTLPExporter(trace.OTLPConfig{Endpoint: "http://127.0.0.1:0"})
+
+	tracer := trace.New(exporter, trace.Config{Probability: 0})
+	defer tracer.Shutdown(context.Background())
+
+	_, span := tracer.Start(context.Background(), "not sampled")
+	if span.IsRecording() || span.SpanContext().IsSampled() {
+		t.Fatalf("Should not record a new trace with a probability of 0")
+	}
+	if !span.SpanContext().IsValid() {
+		t.Fatalf("Should still hand out ids for a trace that isn't sampled")
+	}
+
+	// A trace sampled by the caller is recorded regardless of the probability.
+	remote := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, Flags: trace.FlagSampled, State: "vendor=value"}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), remote)
+
+	_, span = tracer.Start(ctx, "sampled")
+	sc := span.SpanContext()
+	if !span.IsRecording() || sc.TraceID != remote.TraceID || sc.State != remote.State {
+		t.Fatalf("Should continue the trace of the caller : got %+v", sc)
+	}
+
+	// Without a span in the context there is nothing to add to.
+	if _, span := trace.Start(context.Background(), "orphan"); span != nil {
+		t.Fatalf("Should not start a span without a parent")
+	}
+
+	// A nil tracer still hands out ids.
+	var nilTracer *trace.Tracer
+	if _, span := nilTracer.Start(context.Background(), "no tracer"); span.IsRecording() || !span.SpanContext().IsValid() {
+		t.Fatalf("Should hand out ids without recording when there is no tracer")
+	}
+}
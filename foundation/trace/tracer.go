@@ -0,0 +1,201 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Exporter sends ended spans to a tracing backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Config represents the settings of a Tracer.
+type Config struct {
+	// Probability is the share of new traces that are recorded, between 0
+	// and 1. Traces started by another service follow its decision.
+	Probability float64
+
+	// BatchSize is the largest number of spans exported at once. It defaults
+	// to 512.
+	BatchSize int
+
+	// BatchTimeout is how long spans wait for a batch to fill up. It defaults
+	// to 5s.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of spans waiting for export, spans ended while
+	// the queue is full are dropped. It defaults to 2048.
+	QueueSize int
+
+	// ErrorHandler is called when an export fails.
+	ErrorHandler func(err error)
+}
+
+// Tracer starts spans and exports them in batches. A nil Tracer still hands
+// out trace ids, so requests can be correlated, but records nothing.
+type Tracer struct {
+	exporter Exporter
+	cfg      Config
+
+	mu     sync.RWMutex
+	closed bool
+	spans  chan SpanData
+	done   chan struct{}
+}
+
+// New constructs a Tracer exporting the recorded spans with the exporter.
+func New(exporter Exporter, cfg Config) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2048
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(error) {}
+	}
+
+	t := Tracer{
+		exporter: exporter,
+		cfg:      cfg,
+		spans:    make(chan SpanData, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	go t.run()
+
+	return &t
+}
+
+// Start starts a server span for work received from outside the service. The
+// span continues the remote span context held by the context, if any, and
+// starts a new trace otherwise.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent, _ := ctx.Value(remoteKey).(SpanContext)
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.SpanContext()
+	}
+
+	return t.start(ctx, name, parent, SpanKindServer, attrs)
+}
+
+// Shutdown exports the spans still queued. Spans ended after Shutdown are
+// dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.spans)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tracer shutdown: %w", ctx.Err())
+	}
+}
+
+func (t *Tracer) start(ctx context.Context, name string, parent SpanContext, kind SpanKind, attrs []Attribute) (context.Context, *Span) {
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		SpanID:  newSpanID(),
+		Flags:   parent.Flags,
+		State:   parent.State,
+	}
+
+	if !parent.IsValid() {
+		sc.TraceID = newTraceID()
+		sc.Flags = 0
+		sc.State = ""
+		if t != nil && sampled(sc.TraceID, t.cfg.Probability) {
+			sc.Flags |= FlagSampled
+		}
+	}
+
+	span := Span{
+		tracer:    t,
+		recording: t != nil && t.exporter != nil && sc.IsSampled(),
+		data: SpanData{
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Kind:        kind,
+			Start:       time.Now(),
+			Attributes:  attrs,
+		},
+	}
+
+	return ContextWithSpan(ctx, &span), &span
+}
+
+// queue hands the span to the export loop, dropping it when the queue is full
+// or the tracer was shut down.
+func (t *Tracer) queue(data SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return
+	}
+
+	select {
+	case t.spans <- data:
+	default:
+	}
+}
+
+// run exports the queued spans once a batch is full or the batch timeout
+// passed, until the tracer is shut down.
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.cfg.BatchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.cfg.BatchSize)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), t.cfg.BatchTimeout)
+		defer cancel()
+
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			t.cfg.ErrorHandler(fmt.Errorf("exporting %d spans: %w", len(batch), err))
+		}
+
+		batch = make([]SpanData, 0, t.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.spans:
+			if !ok {
+				export()
+				return
+			}
+
+			batch = append(batch, data)
+			if len(batch) >= t.cfg.BatchSize {
+				export()
+			}
+
+		case <-ticker.C:
+			export()
+		}
+	}
+}
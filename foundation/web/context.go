@@ -19,6 +19,21 @@ type Values struct {
 	// captures the current time when the req comes in
 	Now        time.Time
 	StatusCode int
+
+	// Route is the pattern the route was registered with, like /users/:id.
+	Route string
+
+	// Debug asks for the request to be logged in detail, whatever the level
+	// of the logger.
+	Debug bool
+
+	// accept holds the Accept header of the request, used by Respond to
+	// choose an encoder.
+	accept string
+
+	// maxBodyBytes holds the body limit of the App, used when reading the
+	// request body.
+	maxBodyBytes int64
 }
 
 // =============================================================================
@@ -29,7 +44,7 @@ func GetValues(ctx context.Context) *Values {
 	if !ok {
 		/* We don't want to break things. So if the key is not in the ctx, we return a default value. */
 		return &Values{
-			TraceID: "00000000-0000-0000-0000-000000000000",
+			TraceID: "00000000000000000000000000000000",
 			Now:     time.Now(),
 		}
 	}
@@ -41,7 +56,7 @@ func GetValues(ctx context.Context) *Values {
 func GetTraceID(ctx context.Context) string {
 	v, ok := ctx.Value(key).(*Values)
 	if !ok {
-		return "00000000-0000-0000-0000-000000000000"
+		return "00000000000000000000000000000000"
 	}
 	return v.TraceID
 }
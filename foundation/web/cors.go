@@ -0,0 +1,193 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCORSCredentials is returned when a CORS policy allows credentials from
+// any origin.
+var ErrCORSCredentials = errors.New("cors: credentials can't be allowed for any origin")
+
+// CORSConfig represents the cross-origin resource sharing policy of an App.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the App. An entry of
+	// "*" allows any origin and an entry like "https://*.example.com" allows
+	// any subdomain.
+	AllowedOrigins []string
+
+	// AllowedMethods lists the methods allowed in a preflight. It defaults to
+	// the methods registered for the route.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed in a preflight. An
+	// entry of "*" allows any header the client asks for.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers the browser lets scripts
+	// read.
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies and the Authorization header to be sent
+	// along with the requests. It can't be combined with the "*" origin.
+	AllowCredentials bool
+
+	// MaxAge is how long the browser may cache the result of a preflight.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for
+// the origin, or an empty string when the origin isn't allowed.
+func (cfg CORSConfig) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+
+	for _, allowed := range cfg.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return "*"
+
+		case strings.EqualFold(allowed, origin):
+			return origin
+
+		case strings.Contains(allowed, "*."):
+			prefix, suffix, _ := strings.Cut(allowed, "*")
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return origin
+			}
+		}
+	}
+
+	return ""
+}
+
+// CORS sets the CORS headers on the responses to requests coming from an
+// allowed origin. It doesn't answer preflight requests, App.EnableCORS
+// registers a preflight handler for every route.
+func CORS(cfg CORSConfig) Middleware {
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+
+	m := func(handler Handler) Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+
+			w.Header().Add("Vary", "Origin")
+
+			if allow := cfg.allowOrigin(origin); allow != "" {
+				w.Header().Set("Access-Control-Allow-Origin", allow)
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// EnableCORS applies the CORS policy to the routes of the App. Every route
+// added through Handle from now on sends the CORS headers and gets an OPTIONS
+// handler that answers preflight requests. Call it before adding routes.
+// Allowing any origin along with credentials would let any website call the
+// App on behalf of its users, so that policy is rejected.
+func (a *App) EnableCORS(cfg CORSConfig) error {
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		return ErrCORSCredentials
+	}
+
+	a.cors = &cfg
+	a.corsMW = CORS(cfg)
+	a.routeMethods = make(map[string][]string)
+
+	return nil
+}
+
+// preflight answers the preflight request of a route. Requests from an origin,
+// for a method or with headers that aren't allowed get a 403.
+func (a *App) preflight(path string) Handler {
+	cfg := a.cors
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		methods := cfg.AllowedMethods
+		if len(methods) == 0 {
+			methods = a.routeMethods[path]
+		}
+
+		allow := cfg.allowOrigin(r.Header.Get("Origin"))
+		method := r.Header.Get("Access-Control-Request-Method")
+
+		if allow == "" || !slices.Contains(methods, method) {
+			SetStatusCode(ctx, http.StatusForbidden)
+			w.WriteHeader(http.StatusForbidden)
+			return nil
+		}
+
+		headers, ok := allowHeaders(cfg.AllowedHeaders, r.Header.Get("Access-Control-Request-Headers"))
+		if !ok {
+			SetStatusCode(ctx, http.StatusForbidden)
+			w.WriteHeader(http.StatusForbidden)
+			return nil
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allow)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+
+		SetStatusCode(ctx, http.StatusNoContent)
+		w.WriteHeader(http.StatusNoContent)
+
+		return nil
+	}
+
+	return h
+}
+
+// allowHeaders checks the headers of a preflight against the allowed headers
+// and returns the value of the Access-Control-Allow-Headers header.
+func allowHeaders(allowed []string, requested string) (string, bool) {
+	if requested == "" {
+		return "", true
+	}
+
+	if slices.Contains(allowed, "*") {
+		return requested, true
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+
+		if !slices.ContainsFunc(allowed, func(h string) bool { return strings.EqualFold(h, header) }) {
+			return "", false
+		}
+	}
+
+	return strings.Join(allowed, ", "), true
+}
@@ -0,0 +1,121 @@
+package web_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_CORS(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1))
+	err := app.EnableCORS(web.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Should be able to enable CORS : %s", err)
+	}
+
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.RespondJSON(ctx, w, "ok", http.StatusOK)
+	}
+	app.Handle(http.MethodGet, "/users", ok)
+	app.Handle(http.MethodPost, "/users", ok)
+
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		request string
+		headers string
+		status  int
+		allow   string
+	}{
+		{"simple", http.MethodGet, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com"},
+		{"subdomain", http.MethodGet, "https://eu.example.org", "", "", http.StatusOK, "https://eu.example.org"},
+		{"unknown origin", http.MethodGet, "https://evil.com", "", "", http.StatusOK, ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", http.MethodPost, "content-type", http.StatusNoContent, "https://app.example.com"},
+		{"preflight method", http.MethodOptions, "https://app.example.com", http.MethodDelete, "", http.StatusForbidden, ""},
+		{"preflight header", http.MethodOptions, "https://app.example.com", http.MethodPost, "X-Other", http.StatusForbidden, ""},
+		{"preflight origin", http.MethodOptions, "https://example.org", http.MethodGet, "", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users", nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.request != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.request)
+			}
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w := httptest.NewRecorder()
+
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Should receive a status code of %d : got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Fatalf("Should allow the origin %q : got %q", tt.allow, got)
+			}
+			if tt.allow == "" {
+				return
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Fatalf("Should allow credentials : got %q", got)
+			}
+			if tt.method != http.MethodOptions {
+				if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count" {
+					t.Fatalf("Should expose the headers : got %q", got)
+				}
+				return
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+				t.Fatalf("Should allow the methods of the route : got %q", got)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "3600" {
+				t.Fatalf("Should set the max age : got %q", got)
+			}
+		})
+	}
+}
+
+func Test_CORSAnyOrigin(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1))
+
+	err := app.EnableCORS(web.CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if !errors.Is(err, web.ErrCORSCredentials) {
+		t.Fatalf("Should NOT allow credentials from any origin : %v", err)
+	}
+
+	if err := app.EnableCORS(web.CORSConfig{AllowedOrigins: []string{"*"}}); err != nil {
+		t.Fatalf("Should be able to allow any origin without credentials : %s", err)
+	}
+
+	app.Handle(http.MethodGet, "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.RespondJSON(ctx, w, "ok", http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Should allow any origin with the wildcard : got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("Should NOT allow credentials : got %q", got)
+	}
+}
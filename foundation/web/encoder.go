@@ -0,0 +1,380 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotAcceptable is returned by Respond when none of the media types in the
+// Accept header of the request can be produced.
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Set of media types supported out of the box.
+const (
+	MediaTypeJSON   = "application/json"
+	MediaTypeCSV    = "text/csv"
+	MediaTypeNDJSON = "application/x-ndjson"
+)
+
+// EncoderFunc writes the data to w in the format of a media type.
+type EncoderFunc func(w io.Writer, data any) error
+
+// Collection is implemented by values that wrap a list of items with some
+// metadata, like a page of results. Encoders other than JSON receive only the
+// items and the metadata is sent as response headers.
+type Collection interface {
+	CollectionItems() any
+	CollectionHeaders(h http.Header)
+}
+
+type encoder struct {
+	contentType string
+	encode      EncoderFunc
+	envelope    bool
+}
+
+// encoders is the registry of the encoders Respond can choose from, keyed
+// by media type.
+var encoders = struct {
+	mu sync.RWMutex
+	m  map[string]encoder
+}{
+	m: map[string]encoder{
+		MediaTypeJSON:           {contentType: MediaTypeJSON, encode: encodeJSON, envelope: true},
+		MediaTypeCSV:            {contentType: MediaTypeCSV + "; charset=utf-8", encode: encodeCSV},
+		MediaTypeNDJSON:         {contentType: MediaTypeNDJSON, encode: encodeNDJSON},
+		"application/ndjson":    {contentType: MediaTypeNDJSON, encode: encodeNDJSON},
+		"application/jsonlines": {contentType: MediaTypeNDJSON, encode: encodeNDJSON},
+	},
+}
+
+// RegisterEncoder adds an encoder for the media type to the registry used by
+// Respond, replacing any encoder already registered for it. When the data is
+// a Collection, the encoder receives only the items.
+func RegisterEncoder(mediaType string, fn EncoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+
+	encoders.m[mediaType] = encoder{contentType: mediaType, encode: fn}
+}
+
+// Acceptable reports whether Respond can produce one of the media types in
+// the specified Accept header. Handlers can call it to fail early, before
+// doing any expensive work.
+func Acceptable(accept string) bool {
+	_, err := negotiate(accept)
+	return err == nil
+}
+
+// negotiate picks the encoder for the Accept header of a request. A missing
+// header or a wildcard selects JSON.
+func negotiate(accept string) (encoder, error) {
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+
+	if strings.TrimSpace(accept) == "" {
+		return encoders.m[MediaTypeJSON], nil
+	}
+
+	for _, mediaType := range parseAccept(accept) {
+		switch {
+		case mediaType == "*/*" || mediaType == "application/*":
+			return encoders.m[MediaTypeJSON], nil
+
+		case strings.HasSuffix(mediaType, "/*"):
+			prefix := strings.TrimSuffix(mediaType, "*")
+
+			var matches []string
+			for mt := range encoders.m {
+				if strings.HasPrefix(mt, prefix) {
+					matches = append(matches, mt)
+				}
+			}
+
+			if len(matches) > 0 {
+				sort.Strings(matches)
+				return encoders.m[matches[0]], nil
+			}
+
+		default:
+			if enc, exists := encoders.m[mediaType]; exists {
+				return enc, nil
+			}
+		}
+	}
+
+	return encoder{}, ErrNotAcceptable
+}
+
+// parseAccept returns the media types of the Accept header ordered by their
+// quality value. Media types with a quality of 0 are dropped.
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+
+		mr := mediaRange{
+			mediaType: strings.ToLower(strings.TrimSpace(params[0])),
+			q:         1,
+		}
+		if mr.mediaType == "" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	mediaTypes := make([]string, len(ranges))
+	for i, mr := range ranges {
+		mediaTypes[i] = mr.mediaType
+	}
+
+	return mediaTypes
+}
+
+// =============================================================================
+
+func encodeJSON(w io.Writer, data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(jsonData)
+	return err
+}
+
+// encodeNDJSON writes every element of a slice as a JSON document on its own
+// line. Any other value is written as a single line.
+func encodeNDJSON(w io.Writer, data any) error {
+	enc := json.NewEncoder(w)
+
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return enc.Encode(data)
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeCSV writes a slice of structs or maps as CSV with a header row. The
+// columns of a struct are its JSON fields, the columns of a map are its keys
+// in sorted order. Any other value is written as a single row.
+func encodeCSV(w io.Writer, data any) error {
+	rv := reflect.ValueOf(data)
+
+	var rows []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, rv.Index(i))
+		}
+	case reflect.Invalid:
+	default:
+		rows = append(rows, rv)
+	}
+
+	cw := csv.NewWriter(w)
+
+	var columns []string
+	for i, row := range rows {
+		row = indirect(row)
+
+		if i == 0 {
+			columns = csvColumns(row)
+			if err := cw.Write(columns); err != nil {
+				return err
+			}
+		}
+
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = csvCell(csvField(row, column))
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumns returns the column names for a row.
+func csvColumns(row reflect.Value) []string {
+	switch row.Kind() {
+	case reflect.Struct:
+		var columns []string
+		t := row.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if name, ok := jsonName(t.Field(i)); ok {
+				columns = append(columns, name)
+			}
+		}
+		return columns
+
+	case reflect.Map:
+		var columns []string
+		for _, k := range row.MapKeys() {
+			columns = append(columns, fmt.Sprint(k.Interface()))
+		}
+		sort.Strings(columns)
+		return columns
+	}
+
+	return []string{"value"}
+}
+
+// csvField returns the value of the column from a row.
+func csvField(row reflect.Value, column string) reflect.Value {
+	switch row.Kind() {
+	case reflect.Struct:
+		t := row.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if name, ok := jsonName(t.Field(i)); ok && name == column {
+				return row.Field(i)
+			}
+		}
+		return reflect.Value{}
+
+	case reflect.Map:
+		return row.MapIndex(reflect.ValueOf(column).Convert(row.Type().Key()))
+	}
+
+	return row
+}
+
+// formulaPrefixes holds the characters that make a spreadsheet read a cell
+// as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvCell formats a field as a CSV cell. Text that a spreadsheet would run as
+// a formula is prefixed with a quote, numbers are written as they are.
+func csvCell(v reflect.Value) string {
+	value := csvValue(v)
+
+	switch indirect(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		return value
+	}
+
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// csvValue formats a field as the text of a CSV cell. Slices are joined with
+// a comma and nested values are written as JSON.
+func csvValue(v reflect.Value) string {
+	v = indirect(v)
+	if !v.IsValid() {
+		return ""
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = csvValue(v.Index(i))
+		}
+		return strings.Join(values, ",")
+
+	case reflect.Struct, reflect.Map:
+		jsonData, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(jsonData)
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// jsonName returns the name a struct field is marshaled with and false when
+// the field isn't marshaled.
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+
+	return name, true
+}
+
+// indirect follows pointers and interfaces down to the underlying value.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
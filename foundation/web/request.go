@@ -1,10 +1,28 @@
 package web
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dimfeld/httptreemux/v5"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the largest request body accepted when the App
+// doesn't set its own limit.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Set of errors returned while reading a request body.
+var (
+	ErrBodyTooLarge         = errors.New("request body too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrInvalidEncoding      = errors.New("invalid content encoding")
 )
 
 // Param returns the web call parameters from the request.
@@ -22,8 +40,25 @@ type validator interface {
 // body is decoded into the provided value.
 // If the provided value is a struct then it is checked for validation tags.
 // If the value implements a validate function, it is executed.
+// The body may be compressed with gzip or deflate as stated by the
+// Content-Encoding header. ErrUnsupportedMediaType is returned when the
+// Content-Type isn't JSON and ErrBodyTooLarge when the body, once
+// decompressed, is over the limit.
 func Decode(r *http.Request, val any) error {
-	decoder := json.NewDecoder(r.Body)
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != MediaTypeJSON && !strings.HasSuffix(mediaType, "+json")) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, ct)
+		}
+	}
+
+	body, err := Body(r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(val); err != nil {
 		return err
@@ -41,3 +76,110 @@ func Decode(r *http.Request, val any) error {
 
 	return nil
 }
+
+// Body returns the body of the request, decompressed as stated by the
+// Content-Encoding header. Only gzip and deflate are supported, any other
+// encoding returns ErrUnsupportedMediaType. Reading more than the body limit
+// returns ErrBodyTooLarge, which also protects against bodies that expand
+// past the limit once decompressed.
+func Body(r *http.Request) (io.ReadCloser, error) {
+	limit := maxBodyBytes(r.Context())
+
+	var rc io.ReadCloser
+
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		rc = r.Body
+
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		rc = gz
+
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		rc = zr
+
+	default:
+		return nil, fmt.Errorf("%w: content encoding %s", ErrUnsupportedMediaType, enc)
+	}
+
+	return &limitedBody{rc: rc, n: limit}, nil
+}
+
+// bodyError maps the errors of reading a compressed body to the errors of
+// the package.
+func bodyError(err error) error {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return err
+	case errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.Is(err, zlib.ErrHeader), errors.Is(err, zlib.ErrChecksum),
+		errors.Is(err, zlib.ErrDictionary), errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: %s", ErrInvalidEncoding, err)
+	}
+
+	return err
+}
+
+// limitedBody fails with ErrBodyTooLarge once more than n bytes are read.
+type limitedBody struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+
+	n, err := b.rc.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return n, ErrBodyTooLarge
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, bodyError(err)
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
+
+// maxBytesBody reports the error of http.MaxBytesReader as ErrBodyTooLarge.
+type maxBytesBody struct {
+	io.ReadCloser
+}
+
+func (b maxBytesBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return n, ErrBodyTooLarge
+	}
+
+	return n, err
+}
+
+// maxBodyBytes returns the body limit of the App that handles the request.
+func maxBodyBytes(ctx context.Context) int64 {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok || v.maxBodyBytes <= 0 {
+		return DefaultMaxBodyBytes
+	}
+
+	return v.maxBodyBytes
+}
@@ -0,0 +1,107 @@
+package web_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+type newUser struct {
+	Name string `json:"name"`
+}
+
+func gzipBody(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("Should be able to compress the body : %s", err)
+	}
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+func deflateBody(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatalf("Should be able to compress the body : %s", err)
+	}
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+func Test_Decode(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.SetMaxBodyBytes(64)
+
+	app.Handle(http.MethodPost, "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var nu newUser
+		if err := web.Decode(r, &nu); err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, web.ErrBodyTooLarge):
+				status = http.StatusRequestEntityTooLarge
+			case errors.Is(err, web.ErrUnsupportedMediaType):
+				status = http.StatusUnsupportedMediaType
+			case errors.Is(err, web.ErrInvalidEncoding):
+				status = http.StatusBadRequest
+			}
+			return web.RespondJSON(ctx, w, err.Error(), status)
+		}
+
+		return web.RespondJSON(ctx, w, nu, http.StatusOK)
+	})
+
+	large := `{"name":"` + strings.Repeat("a", 100) + `"}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+		status      int
+	}{
+		{"plain", "application/json", "", []byte(`{"name":"Bill"}`), http.StatusOK},
+		{"charset", "application/json; charset=utf-8", "", []byte(`{"name":"Bill"}`), http.StatusOK},
+		{"no content type", "", "", []byte(`{"name":"Bill"}`), http.StatusOK},
+		{"gzip", "application/json", "gzip", gzipBody(t, `{"name":"Bill"}`), http.StatusOK},
+		{"deflate", "application/json", "deflate", deflateBody(t, `{"name":"Bill"}`), http.StatusOK},
+		{"too large", "application/json", "", []byte(large), http.StatusRequestEntityTooLarge},
+		{"too large once decompressed", "application/json", "gzip", gzipBody(t, large), http.StatusRequestEntityTooLarge},
+		{"wrong content type", "text/plain", "", []byte(`{"name":"Bill"}`), http.StatusUnsupportedMediaType},
+		{"unknown encoding", "application/json", "br", []byte(`{"name":"Bill"}`), http.StatusUnsupportedMediaType},
+		{"invalid gzip", "application/json", "gzip", []byte(`{"name":"Bill"}`), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Should receive a status code of %d : got %d : %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status == http.StatusOK && w.Body.String() != `{"name":"Bill"}` {
+				t.Fatalf("Should decode the user : got %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -1,30 +1,56 @@
 package web
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond converts a Go value to the media type asked for in the Accept header
+// of the request and sends it to the client. ErrNotAcceptable is returned,
+// without writing anything, when none of the accepted media types is
+// supported. A Collection is sent whole as JSON, the other media types only
+// receive its items and the metadata is set as response headers.
 func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
-	SetStatusCode(ctx, statusCode)
-
 	if statusCode == http.StatusNoContent {
+		SetStatusCode(ctx, statusCode)
 		w.WriteHeader(statusCode)
 
 		return nil
 	}
 
-	jsonData, err := json.Marshal(data)
+	enc, err := negotiate(GetValues(ctx).accept)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if c, ok := data.(Collection); ok && !enc.envelope {
+		c.CollectionHeaders(w.Header())
+		data = c.CollectionItems()
+	}
+
+	return respond(ctx, w, enc, data, statusCode)
+}
+
+// RespondJSON converts a Go value to JSON and sends it to the client no matter
+// what the client accepts. It's meant for error responses.
+func RespondJSON(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
+	return respond(ctx, w, encoder{contentType: MediaTypeJSON, encode: encodeJSON}, data, statusCode)
+}
+
+func respond(ctx context.Context, w http.ResponseWriter, enc encoder, data any, statusCode int) error {
+	var buf bytes.Buffer
+	if err := enc.encode(&buf, data); err != nil {
+		return err
+	}
+
+	SetStatusCode(ctx, statusCode)
+
+	w.Header().Set("Content-Type", enc.contentType)
+	w.Header().Add("Vary", "Accept")
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(buf.Bytes()); err != nil {
 		return err
 	}
 
@@ -0,0 +1,124 @@
+package web_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type user struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+type page struct {
+	Items []user `json:"items"`
+	Total int    `json:"total"`
+}
+
+func (p page) CollectionItems() any {
+	return p.Items
+}
+
+func (p page) CollectionHeaders(h http.Header) {
+	h.Set("X-Total-Count", "2")
+}
+
+func Test_Respond(t *testing.T) {
+	data := page{
+		Items: []user{
+			{ID: "1", Name: "Bill", Roles: []string{"ADMIN", "USER"}},
+			{ID: "2", Name: "Ale, Jr", Roles: []string{"USER"}},
+		},
+		Total: 2,
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.Handle(http.MethodGet, "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if err := web.Respond(ctx, w, data, http.StatusOK); err != nil {
+			return web.RespondJSON(ctx, w, err.Error(), http.StatusNotAcceptable)
+		}
+		return nil
+	})
+
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		contentType string
+		total       string
+		body        string
+	}{
+		{"default", "", http.StatusOK, "application/json", "", `{"items":[{"id":"1","name":"Bill","roles":["ADMIN","USER"]},{"id":"2","name":"Ale, Jr","roles":["USER"]}],"total":2}`},
+		{"wildcard", "*/*", http.StatusOK, "application/json", "", `{"items":[{"id":"1","name":"Bill","roles":["ADMIN","USER"]},{"id":"2","name":"Ale, Jr","roles":["USER"]}],"total":2}`},
+		{"csv", "text/csv", http.StatusOK, "text/csv; charset=utf-8", "2", "id,name,roles\n1,Bill,\"ADMIN,USER\"\n2,\"Ale, Jr\",USER\n"},
+		{"ndjson", "application/x-ndjson", http.StatusOK, "application/x-ndjson", "2", "{\"id\":\"1\",\"name\":\"Bill\",\"roles\":[\"ADMIN\",\"USER\"]}\n{\"id\":\"2\",\"name\":\"Ale, Jr\",\"roles\":[\"USER\"]}\n"},
+		{"quality", "application/json;q=0.5, text/csv", http.StatusOK, "text/csv; charset=utf-8", "2", "id,name,roles\n1,Bill,\"ADMIN,USER\"\n2,\"Ale, Jr\",USER\n"},
+		{"unsupported", "application/xml", http.StatusNotAcceptable, "application/json", "", `"not acceptable"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Should receive a status code of %d : got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Should receive a content type of %q : got %q", tt.contentType, got)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != tt.total {
+				t.Errorf("Should receive a total count header of %q : got %q", tt.total, got)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("Should receive the expected body\ngot : %s\nwant: %s", got, tt.body)
+			}
+		})
+	}
+}
+
+func Test_RespondCSVFormula(t *testing.T) {
+	type row struct {
+		Name    string   `json:"name"`
+		Balance int      `json:"balance"`
+		Rate    float64  `json:"rate"`
+		Tags    []string `json:"tags"`
+	}
+
+	data := []row{
+		{Name: "=HYPERLINK(\"http://evil\")", Balance: -5, Rate: -0.5, Tags: []string{"+1", "a"}},
+		{Name: "-2+3", Balance: 1, Rate: 1, Tags: []string{"@SUM(A1)"}},
+		{Name: "\tBill", Balance: 0, Rate: 0, Tags: []string{"\rx"}},
+		{Name: "Bill = Ale", Balance: 0, Rate: 0},
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.Handle(http.MethodGet, "/rows", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, data, http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/rows", nil)
+	r.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, r)
+
+	exp := "name,balance,rate,tags\n" +
+		"\"'=HYPERLINK(\"\"http://evil\"\")\",-5,-0.5,\"'+1,a\"\n" +
+		"'-2+3,1,1,'@SUM(A1)\n" +
+		"'\tBill,0,0,\"'\rx\"\n" +
+		"Bill = Ale,0,0,\n"
+	if got := w.Body.String(); got != exp {
+		t.Fatalf("Should prefix the cells a spreadsheet runs as formulas\ngot : %q\nwant: %q", got, exp)
+	}
+}
@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// rowEncoder writes the values of a stream one at a time.
+type rowEncoder interface {
+	encode(v any) error
+	flush() error
+}
+
+type streamEncoder struct {
+	contentType string
+	new         func(w io.Writer) rowEncoder
+}
+
+// streamEncoders holds the media types a Stream can be written in.
+var streamEncoders = map[string]streamEncoder{
+	MediaTypeNDJSON:         {contentType: MediaTypeNDJSON, new: newNDJSONRows},
+	"application/ndjson":    {contentType: MediaTypeNDJSON, new: newNDJSONRows},
+	"application/jsonlines": {contentType: MediaTypeNDJSON, new: newNDJSONRows},
+	MediaTypeCSV:            {contentType: MediaTypeCSV + "; charset=utf-8", new: newCSVRows},
+}
+
+// StreamConfig represents the settings of a Stream.
+type StreamConfig struct {
+	// FlushRows is the number of values written between flushes. It
+	// defaults to 100.
+	FlushRows int
+
+	// WriteTimeout extends the write deadline of the connection after every
+	// flush so a long stream isn't cut by the server's WriteTimeout. Zero
+	// keeps the deadline of the server.
+	WriteTimeout time.Duration
+}
+
+// Stream writes a sequence of values to the client as they are produced, so
+// large results never have to be held in memory. The media type is chosen
+// from the Accept header of the request, NDJSON being the default.
+type Stream struct {
+	ctx     context.Context
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	cfg     StreamConfig
+	enc     rowEncoder
+	ctype   string
+	rows    int
+	started bool
+}
+
+// NewStream constructs a Stream for the request. ErrNotAcceptable is returned
+// when none of the accepted media types can be streamed.
+func NewStream(ctx context.Context, w http.ResponseWriter, cfg StreamConfig) (*Stream, error) {
+	se, err := negotiateStream(GetValues(ctx).accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.FlushRows <= 0 {
+		cfg.FlushRows = 100
+	}
+
+	s := Stream{
+		ctx:   ctx,
+		w:     w,
+		rc:    http.NewResponseController(w),
+		cfg:   cfg,
+		enc:   se.new(w),
+		ctype: se.contentType,
+	}
+
+	return &s, nil
+}
+
+// Write sends the value to the client. The response is started with a 200
+// status on the first call.
+func (s *Stream) Write(v any) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.start()
+
+	if err := s.enc.encode(v); err != nil {
+		return err
+	}
+
+	s.rows++
+	if s.rows%s.cfg.FlushRows == 0 {
+		return s.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends any buffered data to the client and extends the write deadline.
+func (s *Stream) Flush() error {
+	s.start()
+
+	if err := s.enc.flush(); err != nil {
+		return err
+	}
+
+	if err := s.rc.Flush(); err != nil {
+		return err
+	}
+
+	if s.cfg.WriteTimeout > 0 {
+		if err := s.rc.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close flushes what is left of the stream. An empty stream still gets a
+// response with a 200 status.
+func (s *Stream) Close() error {
+	return s.Flush()
+}
+
+// Rows returns the number of values written to the stream.
+func (s *Stream) Rows() int {
+	return s.rows
+}
+
+func (s *Stream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	SetStatusCode(s.ctx, http.StatusOK)
+
+	s.w.Header().Set("Content-Type", s.ctype)
+	s.w.Header().Add("Vary", "Accept")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// negotiateStream picks the stream encoder for the Accept header of a
+// request. A missing header or a wildcard selects NDJSON.
+func negotiateStream(accept string) (streamEncoder, error) {
+	if strings.TrimSpace(accept) == "" {
+		return streamEncoders[MediaTypeNDJSON], nil
+	}
+
+	for _, mediaType := range parseAccept(accept) {
+		switch mediaType {
+		case "*/*", "application/*":
+			return streamEncoders[MediaTypeNDJSON], nil
+		case "text/*":
+			return streamEncoders[MediaTypeCSV], nil
+		}
+
+		if se, exists := streamEncoders[mediaType]; exists {
+			return se, nil
+		}
+	}
+
+	return streamEncoder{}, ErrNotAcceptable
+}
+
+// =============================================================================
+
+type ndjsonRows struct {
+	enc *json.Encoder
+}
+
+func newNDJSONRows(w io.Writer) rowEncoder {
+	return &ndjsonRows{enc: json.NewEncoder(w)}
+}
+
+func (e *ndjsonRows) encode(v any) error {
+	return e.enc.Encode(v)
+}
+
+func (e *ndjsonRows) flush() error {
+	return nil
+}
+
+// csvRows writes the header row from the columns of the first value, the
+// values that follow are written with the same columns.
+type csvRows struct {
+	cw      *csv.Writer
+	columns []string
+}
+
+func newCSVRows(w io.Writer) rowEncoder {
+	return &csvRows{cw: csv.NewWriter(w)}
+}
+
+func (e *csvRows) encode(v any) error {
+	row := indirect(reflect.ValueOf(v))
+
+	if e.columns == nil {
+		e.columns = csvColumns(row)
+		if err := e.cw.Write(e.columns); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		record[i] = csvCell(csvField(row, column))
+	}
+
+	return e.cw.Write(record)
+}
+
+func (e *csvRows) flush() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
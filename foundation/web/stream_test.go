@@ -0,0 +1,71 @@
+package web_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_Stream(t *testing.T) {
+	users := []user{
+		{ID: "1", Name: "Bill", Roles: []string{"ADMIN"}},
+		{ID: "2", Name: "Ale", Roles: []string{"USER"}},
+		{ID: "3", Name: "Jill", Roles: []string{"USER"}},
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.Handle(http.MethodGet, "/export", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		stream, err := web.NewStream(ctx, w, web.StreamConfig{FlushRows: 2})
+		if err != nil {
+			return web.RespondJSON(ctx, w, err.Error(), http.StatusNotAcceptable)
+		}
+
+		for _, usr := range users {
+			if err := stream.Write(usr); err != nil {
+				return err
+			}
+		}
+
+		return stream.Close()
+	})
+
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"default", "", http.StatusOK, "application/x-ndjson", "{\"id\":\"1\",\"name\":\"Bill\",\"roles\":[\"ADMIN\"]}\n{\"id\":\"2\",\"name\":\"Ale\",\"roles\":[\"USER\"]}\n{\"id\":\"3\",\"name\":\"Jill\",\"roles\":[\"USER\"]}\n"},
+		{"csv", "text/csv", http.StatusOK, "text/csv; charset=utf-8", "id,name,roles\n1,Bill,ADMIN\n2,Ale,USER\n3,Jill,USER\n"},
+		{"json", "application/json", http.StatusNotAcceptable, "application/json", `"not acceptable"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/export", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Should receive a status code of %d : got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Should receive a content type of %q : got %q", tt.contentType, got)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("Should receive the expected body\ngot : %s\nwant: %s", got, tt.body)
+			}
+			if tt.status == http.StatusOK && !w.Flushed {
+				t.Errorf("Should have flushed the stream")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
+	"net/http"
+	"strings"
+)
+
+// W3C trace context headers, https://www.w3.org/TR/trace-context/.
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// ParseTraceparent parses the value of a traceparent header. Versions after
+// 00 are parsed as far as they are known, as the specification asks.
+func ParseTraceparent(s string) (trace.SpanContext, error) {
+	var sc trace.SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("traceparent %q: expected 4 fields", s)
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	switch {
+	case err != nil || len(version) != 1 || version[0] == 0xff:
+		return sc, fmt.Errorf("traceparent %q: invalid version", s)
+	case version[0] == 0 && len(parts) != 4:
+		return sc, fmt.Errorf("traceparent %q: expected 4 fields", s)
+	}
+
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil || !sc.TraceID.IsValid() {
+		return sc, fmt.Errorf("traceparent %q: invalid trace id", s)
+	}
+
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil || !sc.SpanID.IsValid() {
+		return sc, fmt.Errorf("traceparent %q: invalid parent id", s)
+	}
+
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return sc, fmt.Errorf("traceparent %q: invalid flags", s)
+	}
+	sc.Flags = trace.Flags(flags[0])
+
+	return sc, nil
+}
+
+// FormatTraceparent formats the span context as a traceparent header.
+func FormatTraceparent(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, byte(sc.Flags&trace.FlagSampled))
+}
+
+// ExtractTraceContext returns the span context carried by the headers of an
+// incoming request. The zero value is returned when there is none or it's
+// invalid, in which case a new trace is started.
+func ExtractTraceContext(h http.Header) trace.SpanContext {
+	sc, err := ParseTraceparent(h.Get(traceparentHeader))
+	if err != nil {
+		return trace.SpanContext{}
+	}
+
+	// Multiple tracestate headers are combined as one list.
+	sc.State = strings.Join(h.Values(tracestateHeader), ",")
+
+	return sc
+}
+
+// InjectTraceContext sets the trace context headers of an outgoing request so
+// the service it calls continues the trace of the current span.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+
+	h.Set(traceparentHeader, FormatTraceparent(sc))
+	if sc.State != "" {
+		h.Set(tracestateHeader, sc.State)
+	}
+}
+
+// decodeHex decodes a lowercase hex string filling exactly dst.
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("expected %d lowercase hex characters", hex.EncodedLen(len(dst)))
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
@@ -0,0 +1,89 @@
+package web_test
+
+import (
+	"context"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/web"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_ParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		valid  bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero parent id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"short", "00-4bf92f3577b34da6a3ce929d0e0e4736-01", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := web.ParseTraceparent(tt.header)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Should parse as valid=%t : got %v", tt.valid, err)
+			}
+			if tt.valid && tt.header[:2] == "00" && web.FormatTraceparent(sc) != tt.header {
+				t.Fatalf("Should format back to the header : got %s", web.FormatTraceparent(sc))
+			}
+		})
+	}
+}
+
+func Test_TraceContextPropagation(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var traceID string
+	var outgoing http.Header
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.Handle(http.MethodGet, "/users", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		traceID = web.GetTraceID(ctx)
+
+		outgoing = make(http.Header)
+		web.InjectTraceContext(ctx, outgoing)
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("traceparent", traceparent)
+	r.Header.Add("tracestate", "congo=t61rcWkgMzE")
+	r.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+	app.ServeHTTP(httptest.NewRecorder(), r)
+
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Should continue the incoming trace : got %s", traceID)
+	}
+
+	sc, err := web.ParseTraceparent(outgoing.Get("traceparent"))
+	if err != nil {
+		t.Fatalf("Should propagate a valid traceparent : %s", err)
+	}
+	if sc.TraceID.String() != traceID || sc.SpanID.String() == "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Fatalf("Should propagate a child of the incoming span : got %s", outgoing.Get("traceparent"))
+	}
+	if got := outgoing.Get("tracestate"); got != "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7" {
+		t.Fatalf("Should propagate the tracestate : got %q", got)
+	}
+
+	// A request without a trace context starts a new trace.
+	r = httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set("traceparent", "garbage")
+	app.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(traceID) != 32 || traceID == "4bf92f3577b34da6a3ce929d0e0e4736" || traceID == (trace.TraceID{}).String() {
+		t.Fatalf("Should start a new trace : got %s", traceID)
+	}
+}
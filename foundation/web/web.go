@@ -4,10 +4,13 @@ package web
 import (
 	"context"
 	"errors"
+	"github.com/Parsa-Sedigh/ardan-go-service-with-kubernetes/foundation/trace"
 	"github.com/dimfeld/httptreemux/v5"
-	"github.com/google/uuid"
 	"net/http"
 	"os"
+	"reflect"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -25,6 +28,16 @@ type App struct {
 
 	// every handler regardless of what it does, is gonna wrapped with the middlewares specified in this field
 	mw []Middleware
+
+	maxBodyBytes int64
+
+	// cors holds the CORS policy when it's enabled, routeMethods the methods
+	// registered for every path so preflight requests can be answered.
+	cors         *CORSConfig
+	corsMW       Middleware
+	routeMethods map[string][]string
+
+	tracer *trace.Tracer
 }
 
 // NewApp creates an App value that handle a set of routes for the application.
@@ -32,12 +45,25 @@ func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
 	// since App represents an API, we use it as a pointer
 	return &App{
 		// ContextMux represents an API therefore use pointer semantics(*ContextMux)
-		ContextMux: httptreemux.NewContextMux(),
-		shutdown:   shutdown,
-		mw:         mw,
+		ContextMux:   httptreemux.NewContextMux(),
+		shutdown:     shutdown,
+		mw:           mw,
+		maxBodyBytes: DefaultMaxBodyBytes,
 	}
 }
 
+// SetMaxBodyBytes sets the largest request body the App accepts. Reading past
+// the limit fails with ErrBodyTooLarge.
+func (a *App) SetMaxBodyBytes(n int64) {
+	a.maxBodyBytes = n
+}
+
+// SetTracer sets the tracer recording a span for every request. Without one,
+// requests still get a trace id, continuing the incoming trace context.
+func (a *App) SetTracer(t *trace.Tracer) {
+	a.tracer = t
+}
+
 // SignalShutdown is used to gracefully shut down the app when an integrity
 // issue is identified. This method issues a SIGTERM.
 func (a *App) SignalShutdown() {
@@ -50,18 +76,64 @@ func (a *App) SignalShutdown() {
 our own version of it.
 Note: Here, we're essentially wrapping code around the handler.*/
 func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) {
+	handler = traceHandler(handler)
 	handler = wrapMiddleware(mw, handler)
 	handler = wrapMiddleware(a.mw, handler)
 
+	// The CORS headers are set first so error responses carry them too.
+	if a.corsMW != nil {
+		handler = a.corsMW(handler)
+	}
+
+	a.bind(method, path, handler)
+
+	// Browsers send a preflight before most cross-origin requests, every path
+	// gets a handler for it the first time a route is added for the path.
+	if a.cors != nil && method != http.MethodOptions {
+		if _, exists := a.routeMethods[path]; !exists {
+			a.bind(http.MethodOptions, path, wrapMiddleware(a.mw, a.preflight(path)))
+		}
+		a.routeMethods[path] = append(a.routeMethods[path], method)
+	}
+}
+
+// bind sets up the values of the request and binds the handler to the mux.
+func (a *App) bind(method string, path string, handler Handler) {
 	// h is the outer layer function(think of the onion)
 	h := func(w http.ResponseWriter, r *http.Request) {
+		// The request span continues the trace of the caller, if any.
+		ctx := trace.ContextWithRemoteSpanContext(r.Context(), ExtractTraceContext(r.Header))
+		ctx, span := a.tracer.Start(ctx, method+" "+path,
+			trace.Attr("http.method", r.Method),
+			trace.Attr("http.route", path),
+			trace.Attr("http.target", r.URL.RequestURI()),
+		)
+
 		v := Values{
-			TraceID:    uuid.NewString(),
-			Now:        time.Time{},
-			StatusCode: 0,
+			TraceID:      span.SpanContext().TraceID.String(),
+			Now:          time.Time{},
+			StatusCode:   0,
+			Route:        path,
+			accept:       r.Header.Get("Accept"),
+			maxBodyBytes: a.maxBodyBytes,
 		}
 
-		ctx := context.WithValue(r.Context(), key, &v)
+		ctx = context.WithValue(ctx, key, &v)
+
+		defer func() {
+			span.SetAttributes(trace.Attr("http.status_code", v.StatusCode))
+			if v.StatusCode >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(v.StatusCode)))
+			}
+			span.End()
+		}()
+
+		// The request carries the values as well so helpers that only get
+		// the request, like Decode, can reach them.
+		r = r.WithContext(ctx)
+		if r.Body != nil && a.maxBodyBytes > 0 {
+			r.Body = maxBytesBody{http.MaxBytesReader(w, r.Body, a.maxBodyBytes)}
+		}
 
 		/* The error returned here means:
 		- the error handler(middleware) has returned it
@@ -83,6 +155,33 @@ func (a *App) Handle(method string, path string, handler Handler, mw ...Middlewa
 	a.ContextMux.Handle(method, path, h)
 }
 
+// traceHandler records a span for the handler itself, a child of the request
+// span, so the time spent in the middlewares can be told apart.
+func traceHandler(handler Handler) Handler {
+	name := handlerName(handler)
+
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		ctx, span := trace.Start(ctx, name)
+		defer span.End()
+
+		err := handler(ctx, w, r)
+		span.SetError(err)
+
+		return err
+	}
+
+	return h
+}
+
+// handlerName returns the name of the handler function, like
+// usergrp.(*Handlers).Query.
+func handlerName(handler Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	return strings.TrimSuffix(name, "-fm")
+}
+
 // validateError validates the error for special conditions that do not
 // warrant an actual shutdown by the system.
 func validateError(err error) bool {